@@ -2,9 +2,9 @@ module path-intellisense-lsp
 
 go 1.25.1
 
-require github.com/sourcegraph/jsonrpc2 v0.2.0
-
 require (
-	github.com/gorilla/websocket v1.5.4-0.20250319132907-e064f32e3674 // indirect
-	golang.org/x/net v0.43.0 // indirect
+	github.com/gorilla/websocket v1.5.4-0.20250319132907-e064f32e3674
+	github.com/sourcegraph/jsonrpc2 v0.2.0
 )
+
+require golang.org/x/net v0.43.0 // indirect
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path-intellisense-lsp/src/handlers"
+	"path/filepath"
+
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Report paths that don't resolve in files on disk, e.g. in CI.
+// Exits with 1 when any are found and 2 when the check couldn't run.
+func check(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	workspace := flags.String("workspace", ".", "Workspace folder that workspace relative paths resolve against")
+	config := flags.String("config", "", "JSON settings file, shaped like initializationOptions")
+	logLevel := flags.String("log-level", os.Getenv("LOG_LEVEL"), "DEBUG, INFO, WARN or ERROR, defaults to $LOG_LEVEL")
+	format := flags.String("format", "text", "Output format: text, json, sarif, junit or github")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s check [flags] [paths...]\n\nChecks files and directories, the workspace folder by default.\n\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	writeReport, ok := reportFormats[*format]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown format %q, expected text, json, sarif, junit or github\n", *format)
+		return 2
+	}
+
+	if err := setupLogging(*logLevel, ""); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if *config != "" {
+		if err := handlers.LoadSettingsFile(*config); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+	}
+	workspaceFolder, err := filepath.Abs(*workspace)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	handlers.SetWorkspaceFolders(&protocol.InitializeParams{RootPath: &workspaceFolder})
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{workspaceFolder}
+	}
+	result, err := handlers.CheckPaths(context.Background(), paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	if err := writeReport(os.Stdout, &checkReport{CheckResult: result, WorkspaceFolder: workspaceFolder}); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(result.BrokenPaths) > 0 {
+		fmt.Fprintf(os.Stderr, "Found %d broken paths in %d checked files\n", len(result.BrokenPaths), len(result.Files))
+		return 1
+	}
+	return 0
+}
+
+// Path relative to the working directory when within it, as CI logs and editors link them
+func displayPath(path string) string {
+	workingDir, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	relativePath, err := filepath.Rel(workingDir, path)
+	if err != nil || !filepath.IsLocal(relativePath) {
+		return path
+	}
+	return relativePath
+}
@@ -6,7 +6,10 @@ import (
 )
 
 type NotifyFunc func(method string, params any)
-type CallFunc func(method string, params any, result any)
+
+// Sends a request to the client and waits for its response, or the error the client replied with.
+// Must not be called on the connection's read loop, e.g. from a notification handler, as the response would never be read.
+type CallFunc func(method string, params any, result any) error
 
 type Context struct {
 	Method  string
@@ -1,13 +1,17 @@
 package handlers
 
 import (
-	"encoding/json"
-	"errors"
+	"context"
+
 	"path-intellisense-lsp/src/glsp"
-	protocol "path-intellisense-lsp/src/protocol_3_16"
 )
 
-func CancelRequest(ctx *glsp.Context, params *protocol.CancelParams) error {
-	message, _ := json.Marshal(params)
-	return errors.New(string(message))
+// Context of a request, cancelled by $/cancelRequest or once the connection closes.
+// Loops over the filesystem check it and return its error, the server then replies
+// with RequestCancelled.
+func requestContext(ctx *glsp.Context) context.Context {
+	if ctx.Context == nil {
+		return context.Background()
+	}
+	return ctx.Context
 }
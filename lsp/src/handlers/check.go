@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Files checked by CheckPaths and the paths in them that don't resolve
+type CheckResult struct {
+	Files       []string // Absolute paths of the files scanned
+	BrokenPaths []BrokenPath
+	Severity    protocol.DiagnosticSeverity // Configured severity of diagnostics of broken paths
+}
+
+// A path in a file on disk that doesn't resolve
+type BrokenPath struct {
+	File         string         // Absolute path of the referring file
+	Range        protocol.Range // Zero-based, characters in the position encoding, UTF-16 outside a client
+	Path         string         // Path as written in the file
+	Base         string         // "file", "workspace", "filesystem", "alias" or "home", empty when no base applies
+	BaseDir      string         // Directory of Base the path was resolved against
+	ExpectedPath string         // Absolute path the file was expected at
+	Fix          *SuggestedFix  // Replacement for Path, when one is clearly intended
+}
+
+type SuggestedFix struct {
+	Title string
+	Path  string // Replacement for the path as written
+}
+
+// Find paths that don't resolve in files and directories on disk, as diagnostics of open documents would.
+// Directories are walked like the workspace index, skipping paths ignored by ".gitignore" files up to their repository,
+// files too large or binary are skipped.
+func CheckPaths(ctx context.Context, paths []string) (*CheckResult, error) {
+	uris := []string{}
+	for _, path := range paths {
+		absolutePath, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		fileInfo, err := os.Stat(absolutePath)
+		if err != nil {
+			return nil, err
+		}
+		if fileInfo.IsDir() {
+			uris = append(uris, walkFileUris(absolutePath, repositoryRoot(absolutePath))...)
+		} else {
+			uris = append(uris, "file://"+absolutePath)
+		}
+	}
+
+	result := &CheckResult{
+		Files:       []string{},
+		BrokenPaths: []BrokenPath{},
+		Severity:    diagnosticSeverity(currentSettings().Diagnostics.Severity),
+	}
+	for _, uri := range uris {
+		text, ok := readFileText(uriPath(uri))
+		if !ok {
+			continue
+		}
+		result.Files = append(result.Files, uriPath(uri))
+		params := &textDocumentPublishDiagnosticsParams{
+			URI:        uri,
+			LanguageID: languageIDOf(uri),
+			content:    newRope(text),
+		}
+		missingPaths, err := textMissingPaths(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, missing := range missingPaths {
+			result.BrokenPaths = append(result.BrokenPaths, brokenPath(missing, params))
+		}
+	}
+	return result, nil
+}
+
+func brokenPath(missing missingPath, params *textDocumentPublishDiagnosticsParams) BrokenPath {
+	broken := BrokenPath{
+		File:         uriPath(params.URI),
+		Range:        missing.Match.Range(missing.Line),
+		Path:         missing.Match.Text,
+		ExpectedPath: missing.AbsolutePath,
+	}
+	if _, base, baseDir, ok := intendedPathResolution(missing.Match.Text, params.URI); ok {
+		broken.Base = string(base)
+		broken.BaseDir = filepath.Clean(baseDir)
+	}
+	if missing.AbsolutePath == "" {
+		return broken
+	}
+
+	// The quick fix an editor would prefer, or the only one offered
+	fixes := pathFixes(pathDiagnosticData{Path: missing.Match.Text, AbsolutePath: missing.AbsolutePath, LanguageID: params.LanguageID})
+	for _, fix := range fixes {
+		if fix.Preferred || len(fixes) == 1 {
+			broken.Fix = &SuggestedFix{Title: fix.Title, Path: fix.Path}
+			break
+		}
+	}
+	return broken
+}
+
+// Closest directory of dir containing ".git", or dir itself outside a repository
+func repositoryRoot(dir string) string {
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(filepath.Join(current, ".git")); err == nil {
+			return current
+		}
+		if filepath.Dir(current) == current {
+			return dir
+		}
+	}
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+const diagnosticSource = "path-intellisense-lsp"
+
+// Attached to "Path not found" diagnostics so quick fixes don't need to re-scan the document
+type pathDiagnosticData struct {
+	Path         string `json:"path"`         // Path text as written
+	AbsolutePath string `json:"absolutePath"` // Where the path was expected to exist
+	LanguageID   string `json:"languageId"`
+}
+
+// Replacement for the first missing segment of a path
+type pathFix struct {
+	Title     string
+	Path      string
+	Preferred bool
+}
+
+func TextDocumentCodeAction(ctx *glsp.Context, params *protocol.CodeActionParams) (any, error) {
+	slog.Debug(fmt.Sprintf("TextDocumentCodeAction for file: %s", params.TextDocument.URI))
+
+	codeActions := []protocol.CodeAction{}
+	if len(params.Context.Only) > 0 && !slices.Contains(params.Context.Only, protocol.CodeActionKindQuickFix) {
+		return codeActions, nil
+	}
+
+	kind := protocol.CodeActionKindQuickFix
+	for _, diagnostic := range params.Context.Diagnostics {
+		data, ok := decodePathDiagnosticData(diagnostic)
+		if !ok {
+			continue
+		}
+
+		for _, fix := range pathFixes(data) {
+			preferred := fix.Preferred
+			codeActions = append(codeActions, protocol.CodeAction{
+				Title:       fix.Title,
+				Kind:        &kind,
+				Diagnostics: []protocol.Diagnostic{diagnostic},
+				IsPreferred: &preferred,
+				Edit: &protocol.WorkspaceEdit{
+					Changes: map[protocol.DocumentUri][]protocol.TextEdit{
+						params.TextDocument.URI: {{Range: diagnostic.Range, NewText: fix.Path}},
+					},
+				},
+			})
+		}
+
+		if clientSupportsResourceOperation(protocol.ResourceOperationKindCreate) {
+			codeActions = append(codeActions, createPathActions(data, diagnostic)...)
+		}
+	}
+	return codeActions, nil
+}
+
+func decodePathDiagnosticData(diagnostic protocol.Diagnostic) (pathDiagnosticData, bool) {
+	var data pathDiagnosticData
+	if diagnostic.Source == nil || *diagnostic.Source != diagnosticSource || diagnostic.Data == nil {
+		return data, false
+	}
+	raw, err := json.Marshal(diagnostic.Data)
+	if err != nil {
+		return data, false
+	}
+	if err := json.Unmarshal(raw, &data); err != nil || data.Path == "" || data.AbsolutePath == "" {
+		return data, false
+	}
+	return data, true
+}
+
+// Suggest fixes for the first missing segment: wrong case, missing extension, then closest name
+func pathFixes(data pathDiagnosticData) []pathFix {
+	segments := strings.Split(strings.TrimSuffix(data.Path, "/"), "/")
+
+	// Walk up to the deepest existing directory
+	missing := filepath.Clean(data.AbsolutePath)
+	depth := 1
+	for {
+		if _, err := os.Stat(filepath.Dir(missing)); err == nil {
+			break
+		}
+		if filepath.Dir(missing) == missing || depth >= len(segments) {
+			return []pathFix{}
+		}
+		missing = filepath.Dir(missing)
+		depth++
+	}
+
+	index := len(segments) - depth
+	name := segments[index]
+	if name == "." || name == ".." || name == "" || name != filepath.Base(missing) {
+		return []pathFix{}
+	}
+	entries, err := os.ReadDir(filepath.Dir(missing))
+	if err != nil {
+		return []pathFix{}
+	}
+
+	replace := func(replacement string) string {
+		fixed := slices.Clone(segments)
+		fixed[index] = replacement
+		return strings.Join(fixed, "/")
+	}
+
+	fixes := []pathFix{}
+	closest := []pathFix{}
+	bestDistance := len(name)/3 + 1
+	for _, entry := range entries {
+		entryName := entry.Name()
+		switch {
+		case strings.EqualFold(entryName, name):
+			fixes = append(fixes, pathFix{Title: fmt.Sprintf("Fix case: %s", replace(entryName)), Path: replace(entryName), Preferred: true})
+
+		case strings.HasPrefix(entryName, name+"."):
+			fixes = append(fixes, pathFix{Title: fmt.Sprintf("Add extension: %s", replace(entryName)), Path: replace(entryName)})
+
+		default:
+			// Compare extension-less names when the path omits its extension
+			candidate := entryName
+			if filepath.Ext(name) == "" {
+				candidate = trimProbeExtension(entryName, data.LanguageID)
+			}
+			distance := editDistance(strings.ToLower(name), strings.ToLower(candidate))
+			if distance < bestDistance {
+				bestDistance = distance
+				closest = []pathFix{}
+			}
+			if distance == bestDistance {
+				closest = append(closest, pathFix{Title: fmt.Sprintf("Replace with closest: %s", replace(candidate)), Path: replace(candidate)})
+			}
+		}
+	}
+	if len(fixes) == 0 && len(closest) == 1 {
+		closest[0].Preferred = true
+	}
+	return append(fixes, closest...)
+}
+
+// Create the missing file, or a folder holding ".gitkeep" for paths without an extension
+func createPathActions(data pathDiagnosticData, diagnostic protocol.Diagnostic) []protocol.CodeAction {
+	kind := protocol.CodeActionKindQuickFix
+	createFile := func(title string, absolutePath string) protocol.CodeAction {
+		return protocol.CodeAction{
+			Title:       title,
+			Kind:        &kind,
+			Diagnostics: []protocol.Diagnostic{diagnostic},
+			Edit: &protocol.WorkspaceEdit{
+				DocumentChanges: []any{protocol.CreateFile{
+					Kind:    "create",
+					URI:     "file://" + absolutePath,
+					Options: &protocol.CreateFileOptions{IgnoreIfExists: &protocol.True},
+				}},
+			},
+		}
+	}
+
+	codeActions := []protocol.CodeAction{}
+	if !strings.HasSuffix(data.Path, "/") {
+		// Extension-less imports create a file with the language's first probe extension
+		absolutePath := data.AbsolutePath
+		if extensions := languageRulesFor(data.LanguageID).Extensions; filepath.Ext(absolutePath) == "" && len(extensions) > 0 {
+			absolutePath += extensions[0]
+		}
+		codeActions = append(codeActions, createFile(fmt.Sprintf("Create file: %s", filepath.Base(absolutePath)), absolutePath))
+	}
+	if filepath.Ext(data.AbsolutePath) == "" {
+		codeActions = append(codeActions, createFile(fmt.Sprintf("Create folder: %s", filepath.Base(data.AbsolutePath)), filepath.Join(data.AbsolutePath, ".gitkeep")))
+	}
+	return codeActions
+}
+
+// Levenshtein distance between two strings
+func editDistance(a string, b string) int {
+	runesA, runesB := []rune(a), []rune(b)
+	previous := make([]int, len(runesB)+1)
+	current := make([]int, len(runesB)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(runesA); i++ {
+		current[0] = i
+		for j := 1; j <= len(runesB); j++ {
+			cost := 1
+			if runesA[i-1] == runesB[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(runesB)]
+}
@@ -3,8 +3,9 @@ package handlers
 import (
 	"fmt"
 	"log/slog"
-	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 
 	"path-intellisense-lsp/src/glsp"
 	protocol "path-intellisense-lsp/src/protocol_3_16"
@@ -14,11 +15,21 @@ func TextDocumentCompletion(ctx *glsp.Context, params *protocol.CompletionParams
 	slog.Debug(fmt.Sprintf("TextDocumentCompletion: %s", params.TextDocument.URI))
 	var completionItems []protocol.CompletionItem
 
+	currentFile, err := currentFiles.Require(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	linePrefix, err := currentFile.LinePrefix(params.Position.Line, params.Position.Character)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate file path syntax
-	text := currentFiles[params.TextDocument.URI].Text
-	line := textLines(text)[params.Position.Line]
-	paths, err := extractPathsRegex(line[:params.Position.Character])
+	paths, err := extractPathsRegex(linePrefix)
 	if err != nil {
+		if slices.Contains(nodeLanguageIDs, currentFile.LanguageID) {
+			return packageCompletionItems(linePrefix, params.TextDocument.URI), nil
+		}
 		return completionItems, nil
 	}
 	path := paths[len(paths)-1]
@@ -26,12 +37,20 @@ func TextDocumentCompletion(ctx *glsp.Context, params *protocol.CompletionParams
 	suggestedAbsolutePaths := matchPath(path, params.TextDocument.URI, "*")
 
 	// Format suggested paths
+	showHiddenFiles := currentSettings().ShowHiddenFiles
+	inImport := isImportContext(linePrefix, currentFile.LanguageID)
 	for _, suggestedAbsolutePath := range suggestedAbsolutePaths {
+		// Large directories take a stat per entry, stop once the client moved on
+		if err := requestContext(ctx).Err(); err != nil {
+			return nil, err
+		}
 		_, suggestion := filepath.Split(suggestedAbsolutePath)
+		if !showHiddenFiles && strings.HasPrefix(suggestion, ".") {
+			continue
+		}
 		doc := documentPathMarkdown(path+suggestion, suggestedAbsolutePath)
 
-		fileInfo, err := os.Stat(suggestedAbsolutePath)
-		if err == nil && fileInfo.IsDir() {
+		if isDirPath(suggestedAbsolutePath) {
 			detail := "📂 Folder"
 			kind := protocol.CompletionItemKindFolder
 			completionItems = append(completionItems, protocol.CompletionItem{
@@ -47,6 +66,11 @@ func TextDocumentCompletion(ctx *glsp.Context, params *protocol.CompletionParams
 		} else {
 			detail := "📄 File"
 			kind := protocol.CompletionItemKindFile
+			insertText := suggestion
+			// Imports usually omit the extension, e.g. "./utils/format" for "format.ts"
+			if inImport {
+				insertText = trimProbeExtension(suggestion, currentFile.LanguageID)
+			}
 			completionItems = append(completionItems, protocol.CompletionItem{
 				Label:  suggestion,
 				Kind:   &kind,
@@ -55,13 +79,50 @@ func TextDocumentCompletion(ctx *glsp.Context, params *protocol.CompletionParams
 					Kind:  protocol.MarkupKindMarkdown,
 					Value: "**📄 File**\n" + doc,
 				},
-				InsertText: &suggestion,
+				InsertText: &insertText,
 			})
 		}
 	}
 	return completionItems, nil
 }
 
+// Check whether the cursor is inside an import or require string, where probe extensions may be omitted.
+// Other strings, e.g. readFileSync("./a/b.ts"), need the file's full name.
+func isImportContext(linePrefix string, languageID string) bool {
+	if !slices.Contains(nodeLanguageIDs, languageID) {
+		return false
+	}
+	return mustCompileLazyRegex(importSpecifierPrefix + "[^\"']*$").MatchString(linePrefix)
+}
+
+// Suggest package names from node_modules for a partially typed import specifier
+func packageCompletionItems(text string, fileUri string) []protocol.CompletionItem {
+	completionItems := []protocol.CompletionItem{}
+	prefix, ok := extractPackagePrefix(text)
+	if !ok {
+		return completionItems
+	}
+	for _, name := range nodePackageNames(fileUri, prefix) {
+		packageDir, ok := nodePackageDir(name, fileUri)
+		if !ok {
+			continue
+		}
+		detail := "📦 Package"
+		kind := protocol.CompletionItemKindModule
+		completionItems = append(completionItems, protocol.CompletionItem{
+			Label:  name,
+			Kind:   &kind,
+			Detail: &detail,
+			Documentation: protocol.MarkupContent{
+				Kind:  protocol.MarkupKindMarkdown,
+				Value: "**📦 Package**\n" + documentPackageMarkdown(name, packageDir),
+			},
+			InsertText: &name,
+		})
+	}
+	return completionItems
+}
+
 func documentPathMarkdown(inputPath, absolutePath string) string {
 	return fmt.Sprintf(`
 **Input path:**
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+func TextDocumentDefinition(ctx *glsp.Context, params *protocol.DefinitionParams) (any, error) {
+	slog.Debug(fmt.Sprintf("TextDocumentDefinition for file: %s", params.TextDocument.URI))
+	capabilities := clientCapabilities.TextDocument
+	linkSupport := capabilities != nil && capabilities.Definition != nil && capabilities.Definition.LinkSupport != nil && *capabilities.Definition.LinkSupport
+	return pathDefinition(params.TextDocumentPositionParams, linkSupport), nil
+}
+
+func TextDocumentDeclaration(ctx *glsp.Context, params *protocol.DeclarationParams) (any, error) {
+	slog.Debug(fmt.Sprintf("TextDocumentDeclaration for file: %s", params.TextDocument.URI))
+	capabilities := clientCapabilities.TextDocument
+	linkSupport := capabilities != nil && capabilities.Declaration != nil && capabilities.Declaration.LinkSupport != nil && *capabilities.Declaration.LinkSupport
+	return pathDefinition(params.TextDocumentPositionParams, linkSupport), nil
+}
+
+// Locate the file the path under the cursor resolves to.
+// Returns []LocationLink when supported so the origin covers exactly the path text, else []Location.
+func pathDefinition(params protocol.TextDocumentPositionParams, linkSupport bool) any {
+	match, ok := pathMatchAt(params.TextDocument.URI, params.Position)
+	if !ok {
+		return nil
+	}
+
+	originRange := match.Range(int(params.Position.Line))
+	targetRange := protocol.Range{}
+	locations := []protocol.Location{}
+	locationLinks := []protocol.LocationLink{}
+	for _, absolutePath := range matchPath(match.Text, params.TextDocument.URI, "") {
+		if isDirPath(absolutePath) && currentSettings().JumpToIndexFile {
+			if indexPath, ok := probeIndexFile(absolutePath, languageIDOf(params.TextDocument.URI)); ok {
+				absolutePath = indexPath
+			}
+		}
+
+		target := "file://" + absolutePath
+		locations = append(locations, protocol.Location{URI: target, Range: targetRange})
+		locationLinks = append(locationLinks, protocol.LocationLink{
+			OriginSelectionRange: &originRange,
+			TargetURI:            target,
+			TargetRange:          targetRange,
+			TargetSelectionRange: targetRange,
+		})
+	}
+
+	if linkSupport {
+		return locationLinks
+	}
+	return locations
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"path-intellisense-lsp/src/glsp"
@@ -8,40 +9,47 @@ import (
 )
 
 type textDocumentPublishDiagnosticsParams struct {
-	URI     string
-	Version int32
-	Text    string
+	URI        string
+	Version    int32
+	LanguageID string
+
+	content rope // Text of the document, for rescanning single lines
 }
 
-func textDocumentPublishDiagnostics(ctx *glsp.Context, params *textDocumentPublishDiagnosticsParams) {
-	slog.Debug(fmt.Sprintf("TextDocumentPublishDiagnostics for file: %s", params.URI))
+// Diagnostics of paths that don't resolve, or an error if ctx was cancelled first
+func pathDiagnostics(ctx context.Context, params *textDocumentPublishDiagnosticsParams) ([]protocol.Diagnostic, error) {
+	slog.Debug(fmt.Sprintf("Computing diagnostics for file: %s", params.URI))
 
+	settings := currentSettings()
 	diagnostics := []protocol.Diagnostic{}
-	for i, line := range textLines(params.Text) {
-		for _, match := range findPathMatches(line) {
-			if len(matchPath(match.Text, params.URI, "")) > 0 {
-				continue
-			}
-			severity := protocol.DiagnosticSeverityError
-			source := "path-intellisense-lsp"
-			diagnostics = append(diagnostics, protocol.Diagnostic{
-				Range: protocol.Range{
-					Start: protocol.Position{
-						Line:      uint32(i),
-						Character: uint32(match.Start),
-					},
-					End: protocol.Position{
-						Line:      uint32(i),
-						Character: uint32(match.End),
-					},
-				},
-				Severity: &severity,
-				Source:   &source,
-				Message:  fmt.Sprintf("Path not found: %s", match.Text),
-			})
-		}
+	// Publish no diagnostics to clear previous ones when disabled
+	if !settings.Diagnostics.Enable {
+		return diagnostics, nil
+	}
+	missingPaths, err := documentMissingPaths(ctx, params)
+	if err != nil {
+		return nil, err
 	}
+	for _, missing := range missingPaths {
+		severity := diagnosticSeverity(settings.Diagnostics.Severity)
+		source := diagnosticSource
+		diagnostics = append(diagnostics, protocol.Diagnostic{
+			Range:    missing.Match.Range(missing.Line),
+			Severity: &severity,
+			Source:   &source,
+			Message:  fmt.Sprintf("Path not found: %s", missing.Match.Text),
+			Data: pathDiagnosticData{
+				Path:         missing.Match.Text,
+				AbsolutePath: missing.AbsolutePath,
+				LanguageID:   params.LanguageID,
+			},
+		})
+	}
+	return diagnostics, nil
+}
 
+func textDocumentPublishDiagnostics(ctx *glsp.Context, params *textDocumentPublishDiagnosticsParams, diagnostics []protocol.Diagnostic) {
+	slog.Debug(fmt.Sprintf("TextDocumentPublishDiagnostics for file: %s", params.URI))
 	version := uint32(params.Version)
 	ctx.Notify(protocol.ServerTextDocumentPublishDiagnostics, &protocol.PublishDiagnosticsParams{
 		URI:         params.URI,
@@ -0,0 +1,233 @@
+package handlers
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Lines replaced by a content change: old lines Start to End inclusive became Lines new lines
+type lineEdit struct {
+	Start int
+	End   int
+	Lines int
+}
+
+// Path matches of a line and the ones that don't resolve, reused until the line is edited
+type lineDiagnostics struct {
+	Matches    []pathMatch
+	Missing    []missingPath
+	Generation uint64 // Resolution generation Missing was computed in
+}
+
+type missingPath struct {
+	Line         int // Only set on results handed out, cached results move between lines
+	Match        pathMatch
+	AbsolutePath string
+}
+
+// Per-line results of the last diagnostics of a document version, nil lines need a rescan
+type documentDiagnostics struct {
+	Version    int32
+	LanguageID string
+	Lines      []*lineDiagnostics
+}
+
+type resolutionKey struct {
+	Path       string
+	FileURI    string
+	LanguageID string
+}
+
+var (
+	diagnosticsCache     = map[string]*documentDiagnostics{}
+	diagnosticsCacheLock sync.Mutex
+
+	// Whether a path string resolves from a file, cleared when files or settings change
+	resolutionCache      = map[resolutionKey]bool{}
+	resolutionGeneration uint64
+	resolutionCacheLock  sync.Mutex
+)
+
+// Mark edited lines of a document for rescan, moving the results of lines after them.
+// Results are dropped unless they belong to the version the edits were applied to.
+func shiftDiagnosticLines(uri string, fromVersion int32, toVersion int32, edits []lineEdit) {
+	diagnosticsCacheLock.Lock()
+	defer diagnosticsCacheLock.Unlock()
+	cached, ok := diagnosticsCache[uri]
+	if !ok {
+		return
+	}
+	if cached.Version != fromVersion {
+		delete(diagnosticsCache, uri)
+		return
+	}
+	for _, edit := range edits {
+		if edit.Start < 0 || edit.End < edit.Start || edit.End >= len(cached.Lines) {
+			delete(diagnosticsCache, uri)
+			return
+		}
+		cached.Lines = append(cached.Lines[:edit.Start], append(make([]*lineDiagnostics, edit.Lines), cached.Lines[edit.End+1:]...)...)
+	}
+	cached.Version = toVersion
+}
+
+// Drop the cached results of a document, e.g. once it is closed
+func forgetDiagnostics(uri string) {
+	diagnosticsCacheLock.Lock()
+	defer diagnosticsCacheLock.Unlock()
+	delete(diagnosticsCache, uri)
+}
+
+// Drop cached resolutions after files, config files or workspace folders changed
+func invalidateResolutions() {
+	resolutionCacheLock.Lock()
+	defer resolutionCacheLock.Unlock()
+	clear(resolutionCache)
+	resolutionGeneration++
+}
+
+// Drop every cached result after settings changed how paths are matched and resolved
+func invalidateDiagnostics() {
+	diagnosticsCacheLock.Lock()
+	clear(diagnosticsCache)
+	diagnosticsCacheLock.Unlock()
+	invalidateResolutions()
+}
+
+func currentResolutionGeneration() uint64 {
+	resolutionCacheLock.Lock()
+	defer resolutionCacheLock.Unlock()
+	return resolutionGeneration
+}
+
+// Check whether a path string resolves from a file, reusing earlier filesystem lookups
+func pathResolves(path string, fileUri string, languageID string) bool {
+	key := resolutionKey{Path: path, FileURI: fileUri, LanguageID: languageID}
+	resolutionCacheLock.Lock()
+	resolves, ok := resolutionCache[key]
+	generation := resolutionGeneration
+	resolutionCacheLock.Unlock()
+	if ok {
+		return resolves
+	}
+
+	resolves = len(matchPathInLanguage(path, fileUri, "", languageID)) > 0
+	resolutionCacheLock.Lock()
+	defer resolutionCacheLock.Unlock()
+	// Don't cache a lookup that raced with an invalidation
+	if generation == resolutionGeneration {
+		resolutionCache[key] = resolves
+	}
+	return resolves
+}
+
+// Check whether a path match should be reported as not found
+func isMissingPath(path string, fileUri string, languageID string) bool {
+	if pathResolves(path, fileUri, languageID) {
+		return false
+	}
+	// Bare paths are only checked when their first segment exists, to avoid flagging prose like "and/or"
+	if isBarePath(path) && !isAliasPath(path, fileUri) {
+		firstSegment, _, _ := strings.Cut(path, "/")
+		if !pathResolves(firstSegment, fileUri, languageID) {
+			return false
+		}
+	}
+	return true
+}
+
+// Paths of a document that don't resolve, rescanning only lines that were edited
+// and re-resolving only lines whose outcomes predate the last invalidation.
+// Scans a snapshot of the cached lines without holding the lock, so edits are recorded meanwhile,
+// and caches the results unless the document changed. Stops between lines once ctx is cancelled,
+// lines scanned so far stay cached.
+func documentMissingPaths(ctx context.Context, params *textDocumentPublishDiagnosticsParams) ([]missingPath, error) {
+	// Files on disk can change without a new version, so only open documents are cached
+	if _, open := currentFiles.Get(params.URI); !open {
+		return textMissingPaths(ctx, params)
+	}
+
+	diagnosticsCacheLock.Lock()
+	cached, ok := diagnosticsCache[params.URI]
+	if !ok || cached.Version != params.Version || cached.LanguageID != params.LanguageID || len(cached.Lines) != params.content.LineCount() {
+		cached = &documentDiagnostics{
+			Version:    params.Version,
+			LanguageID: params.LanguageID,
+			Lines:      make([]*lineDiagnostics, params.content.LineCount()),
+		}
+		diagnosticsCache[params.URI] = cached
+	}
+	lines := slices.Clone(cached.Lines)
+	diagnosticsCacheLock.Unlock()
+	defer storeDiagnosticLines(params.URI, cached, params.Version, lines)
+
+	// Cached results are shared with other scans, so updated lines get new results
+	generation := currentResolutionGeneration()
+	missingPaths := []missingPath{}
+	for i, result := range lines {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if result == nil {
+			line, err := params.content.Line(i)
+			if err != nil {
+				line = ""
+			}
+			result = &lineDiagnostics{Matches: findPathMatches(line), Generation: generation - 1}
+		}
+		if result.Generation != generation {
+			result = &lineDiagnostics{Matches: result.Matches, Missing: lineMissingPaths(result.Matches, params), Generation: generation}
+		}
+		lines[i] = result
+		for _, missing := range result.Missing {
+			missing.Line = i
+			missingPaths = append(missingPaths, missing)
+		}
+	}
+	return missingPaths, nil
+}
+
+// Cache the lines of a scan, unless the document was edited or forgotten since the snapshot was taken
+func storeDiagnosticLines(uri string, cached *documentDiagnostics, version int32, lines []*lineDiagnostics) {
+	diagnosticsCacheLock.Lock()
+	defer diagnosticsCacheLock.Unlock()
+	if diagnosticsCache[uri] != cached || cached.Version != version || len(cached.Lines) != len(lines) {
+		return
+	}
+	for i, result := range lines {
+		// Keep lines another scan finished meanwhile
+		if result != nil && (cached.Lines[i] == nil || cached.Lines[i].Generation < result.Generation) {
+			cached.Lines[i] = result
+		}
+	}
+}
+
+// Paths of a document that don't resolve, scanning every line
+func textMissingPaths(ctx context.Context, params *textDocumentPublishDiagnosticsParams) ([]missingPath, error) {
+	missingPaths := []missingPath{}
+	for i, line := range params.content.Lines() {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		for _, missing := range lineMissingPaths(findPathMatches(line), params) {
+			missing.Line = i
+			missingPaths = append(missingPaths, missing)
+		}
+	}
+	return missingPaths, nil
+}
+
+// Matches of a line that don't resolve
+func lineMissingPaths(matches []pathMatch, params *textDocumentPublishDiagnosticsParams) []missingPath {
+	missingPaths := []missingPath{}
+	for _, match := range matches {
+		if !isMissingPath(match.Text, params.URI, params.LanguageID) {
+			continue
+		}
+		absolutePath, _ := intendedAbsolutePath(match.Text, params.URI)
+		missingPaths = append(missingPaths, missingPath{Match: match, AbsolutePath: absolutePath})
+	}
+	return missingPaths
+}
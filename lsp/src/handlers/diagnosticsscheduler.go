@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"path-intellisense-lsp/src/glsp"
+)
+
+// Pending or running diagnostics of a document version
+type diagnosticsJob struct {
+	Version int32
+	cancel  context.CancelFunc
+}
+
+var (
+	diagnosticsJobs     = map[string]*diagnosticsJob{} // Document URI -> latest scheduled job
+	diagnosticsJobsLock sync.Mutex
+	diagnosticsRunning  sync.WaitGroup // Jobs not finished yet, including cancelled ones
+)
+
+// Delay after a change before its diagnostics are computed, so fast typing publishes once
+func diagnosticsDelay() time.Duration {
+	return time.Duration(max(currentSettings().Diagnostics.Delay, 0)) * time.Millisecond
+}
+
+// Publish diagnostics of a document after delay, cancelling work scheduled for it before.
+// The job stops with the connection's context, and its results are dropped if the document
+// changed in the meantime.
+func scheduleDiagnostics(ctx *glsp.Context, params *textDocumentPublishDiagnosticsParams, delay time.Duration) {
+	// Clients pulling diagnostics ask for them instead
+	if clientSupportsPullDiagnostics() {
+		return
+	}
+	jobContext, cancel := context.WithCancel(requestContext(ctx))
+	job := &diagnosticsJob{Version: params.Version, cancel: cancel}
+
+	diagnosticsJobsLock.Lock()
+	if previous, ok := diagnosticsJobs[params.URI]; ok {
+		previous.cancel()
+	}
+	diagnosticsJobs[params.URI] = job
+	diagnosticsJobsLock.Unlock()
+
+	diagnosticsRunning.Add(1)
+	go func() {
+		defer diagnosticsRunning.Done()
+		defer finishDiagnosticsJob(params.URI, job)
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-jobContext.Done():
+			return
+		case <-timer.C:
+		}
+		diagnostics, err := pathDiagnostics(jobContext, params)
+		if err != nil {
+			slog.Debug(fmt.Sprintf("Cancelled diagnostics of %s version %d: %s", params.URI, params.Version, err))
+			return
+		}
+
+		// Hold the lock so no newer job can be scheduled between the check and the publish
+		diagnosticsJobsLock.Lock()
+		defer diagnosticsJobsLock.Unlock()
+		if jobContext.Err() != nil || !isCurrentDiagnosticsJob(params.URI, job) {
+			slog.Debug(fmt.Sprintf("Dropped stale diagnostics of %s version %d", params.URI, params.Version))
+			return
+		}
+		textDocumentPublishDiagnostics(ctx, params, diagnostics)
+	}()
+}
+
+// Check whether a job still holds the latest version of its document, called with diagnosticsJobsLock held
+func isCurrentDiagnosticsJob(uri string, job *diagnosticsJob) bool {
+	if diagnosticsJobs[uri] != job {
+		return false
+	}
+	currentFile, ok := currentFiles.Get(uri)
+	return ok && currentFile.Version == job.Version
+}
+
+func finishDiagnosticsJob(uri string, job *diagnosticsJob) {
+	diagnosticsJobsLock.Lock()
+	defer diagnosticsJobsLock.Unlock()
+	job.cancel()
+	if diagnosticsJobs[uri] == job {
+		delete(diagnosticsJobs, uri)
+	}
+}
+
+// Cancel scheduled diagnostics of a document, e.g. once it is closed
+func cancelDiagnostics(uri string) {
+	diagnosticsJobsLock.Lock()
+	defer diagnosticsJobsLock.Unlock()
+	if job, ok := diagnosticsJobs[uri]; ok {
+		job.cancel()
+		delete(diagnosticsJobs, uri)
+	}
+}
+
+// Cancel every scheduled diagnostics job and wait for them to stop, e.g. on shutdown
+func cancelAllDiagnostics() {
+	diagnosticsJobsLock.Lock()
+	for uri, job := range diagnosticsJobs {
+		job.cancel()
+		delete(diagnosticsJobs, uri)
+	}
+	diagnosticsJobsLock.Unlock()
+	diagnosticsRunning.Wait()
+}
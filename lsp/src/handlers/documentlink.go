@@ -3,41 +3,41 @@ package handlers
 import (
 	"fmt"
 	"log/slog"
-	"os"
 	"path-intellisense-lsp/src/glsp"
 	protocol "path-intellisense-lsp/src/protocol_3_16"
-	"path/filepath"
 	"strings"
 )
 
 func TextDocumentDocumentLink(ctx *glsp.Context, params *protocol.DocumentLinkParams) ([]protocol.DocumentLink, error) {
 	slog.Debug(fmt.Sprintf("TextDocumentDocumentLink for file: %s", params.TextDocument.URI))
 
+	currentFile, err := currentFiles.Require(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
 	documentLinks := []protocol.DocumentLink{}
-	for i, line := range textLines(currentFiles[params.TextDocument.URI].Text) {
-		for _, match := range findPathMatches(line) {
+	for i, line := range currentFile.content.Lines() {
+		if err := requestContext(ctx).Err(); err != nil {
+			return nil, err
+		}
+		for _, match := range linePathMatches(line, currentFile.LanguageID) {
 			for _, absolutePath := range matchPath(match.Text, params.TextDocument.URI, "") {
-				target := "file://" + absolutePath
-				absoluteDir, _ := filepath.Split(params.TextDocument.URI[7:])
+				absoluteDir, _ := pathBaseDir(pathBaseFile, params.TextDocument.URI)
 
 				tooltip := "📄 File: "
-				fileInfo, err := os.Stat(absolutePath)
-				if err == nil && fileInfo.IsDir() {
-					tooltip = "📂 Folder: "
+				if isDirPath(absolutePath) {
+					// Link directories to their entry file, e.g. "index.ts"
+					if indexPath, ok := probeIndexFile(absolutePath, currentFile.LanguageID); ok {
+						absolutePath = indexPath
+					} else {
+						tooltip = "📂 Folder: "
+					}
 				}
+				target := "file://" + absolutePath
 				tooltip += strings.Replace(absolutePath, absoluteDir, "./", 1)
 
 				documentLinks = append(documentLinks, protocol.DocumentLink{
-					Range: protocol.Range{
-						Start: protocol.Position{
-							Line:      uint32(i),
-							Character: uint32(match.Start),
-						},
-						End: protocol.Position{
-							Line:      uint32(i),
-							Character: uint32(match.End),
-						},
-					},
+					Range:   match.Range(i),
 					Target:  &target,
 					Tooltip: &tooltip,
 				})
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Open documents, written by sync handlers and read by request handlers and background work.
+// Documents are stored and handed out by value, so a snapshot never changes under its reader.
+type documentStore struct {
+	lock      sync.RWMutex
+	documents map[string]CurrentFile
+}
+
+var currentFiles = &documentStore{documents: map[string]CurrentFile{}}
+
+func documentNotOpenError(uri string) error {
+	return fmt.Errorf("document not open: %s", uri)
+}
+
+// Snapshot of an open document
+func (s *documentStore) Get(uri string) (CurrentFile, bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	currentFile, ok := s.documents[uri]
+	return currentFile, ok
+}
+
+// Snapshot of an open document, or an error for handlers to return
+func (s *documentStore) Require(uri string) (CurrentFile, error) {
+	currentFile, ok := s.Get(uri)
+	if !ok {
+		return CurrentFile{}, documentNotOpenError(uri)
+	}
+	return currentFile, nil
+}
+
+func (s *documentStore) Open(currentFile CurrentFile) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.documents[currentFile.Path] = currentFile
+}
+
+// Apply update to a copy of the document and store it unless update fails
+func (s *documentStore) Update(uri string, update func(currentFile *CurrentFile) error) (CurrentFile, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	currentFile, ok := s.documents[uri]
+	if !ok {
+		return CurrentFile{}, documentNotOpenError(uri)
+	}
+	if err := update(&currentFile); err != nil {
+		return CurrentFile{}, err
+	}
+	s.documents[uri] = currentFile
+	return currentFile, nil
+}
+
+func (s *documentStore) Close(uri string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.documents, uri)
+}
+
+// Snapshots of all open documents, sorted by URI
+func (s *documentStore) All() []CurrentFile {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	currentFiles := make([]CurrentFile, 0, len(s.documents))
+	for _, currentFile := range s.documents {
+		currentFiles = append(currentFiles, currentFile)
+	}
+	slices.SortFunc(currentFiles, func(a CurrentFile, b CurrentFile) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return currentFiles
+}
+
+// Text of a line, or an error when the client sent a position past the end of the document
+func (s CurrentFile) Line(line uint32) (string, error) {
+	text, err := s.content.Line(int(line))
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", s.Path, err)
+	}
+	return text, nil
+}
+
+// Text of a line up to a character, the part completion looks at
+func (s CurrentFile) LinePrefix(line uint32, character uint32) (string, error) {
+	text, err := s.Line(line)
+	if err != nil {
+		return "", err
+	}
+	return text[:byteOffset(text, character)], nil
+}
+
+func (s CurrentFile) diagnosticsParams() *textDocumentPublishDiagnosticsParams {
+	return &textDocumentPublishDiagnosticsParams{
+		URI:        s.Path,
+		Version:    s.Version,
+		LanguageID: s.LanguageID,
+		content:    s.content,
+	}
+}
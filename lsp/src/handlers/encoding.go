@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"slices"
+
+	protocol317 "path-intellisense-lsp/src/protocol_3_17"
+)
+
+// Unit of Position.Character agreed on at initialize, UTF-16 unless the client offers another
+var positionEncoding = protocol317.PositionEncodingKindUTF16
+
+// Pick the position encoding from the client's offer.
+// UTF-8 is preferred because it matches Go strings and needs no conversion.
+func NegotiatePositionEncoding(capabilities protocol317.ClientCapabilities) protocol317.PositionEncodingKind {
+	positionEncoding = protocol317.PositionEncodingKindUTF16
+	if capabilities.General != nil {
+		offered := capabilities.General.PositionEncodings
+		if slices.Contains(offered, protocol317.PositionEncodingKindUTF8) {
+			positionEncoding = protocol317.PositionEncodingKindUTF8
+		} else if slices.Contains(offered, protocol317.PositionEncodingKindUTF32) && !slices.Contains(offered, protocol317.PositionEncodingKindUTF16) {
+			positionEncoding = protocol317.PositionEncodingKindUTF32
+		}
+	}
+	return positionEncoding
+}
+
+// Byte offset of a protocol character offset on a line.
+// Characters past the end of the line default back to its length, as the specification requires.
+func byteOffset(line string, character uint32) int {
+	if positionEncoding == protocol317.PositionEncodingKindUTF8 {
+		return min(int(character), len(line))
+	}
+	units := uint32(0)
+	for offset, char := range line {
+		if units >= character {
+			return offset
+		}
+		units += encodedLength(char)
+		// Offsets inside a surrogate pair round down to the start of the character
+		if units > character {
+			return offset
+		}
+	}
+	return len(line)
+}
+
+// Protocol character offset of a byte offset on a line
+func characterOffset(line string, offset int) uint32 {
+	if positionEncoding == protocol317.PositionEncodingKindUTF8 {
+		return uint32(offset)
+	}
+	units := uint32(0)
+	for _, char := range line[:min(offset, len(line))] {
+		units += encodedLength(char)
+	}
+	return units
+}
+
+// Code units of a character in the negotiated encoding
+func encodedLength(char rune) uint32 {
+	if positionEncoding == protocol317.PositionEncodingKindUTF32 {
+		return 1
+	}
+	if char >= 0x10000 {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Rewrite path strings across the workspace before the client renames or moves files and folders
+func WorkspaceWillRenameFiles(ctx *glsp.Context, params *protocol.RenameFilesParams) (*protocol.WorkspaceEdit, error) {
+	slog.Debug(fmt.Sprintf("WorkspaceWillRenameFiles: %d files", len(params.Files)))
+
+	moves := []pathMove{}
+	for _, file := range params.Files {
+		moves = append(moves, pathMove{Old: uriPath(file.OldURI), New: uriPath(file.NewURI)})
+	}
+	edits := pathRenameTextEdits(moves)
+	if len(edits) == 0 {
+		return nil, nil
+	}
+	if clientSupportsDocumentChanges() {
+		return &protocol.WorkspaceEdit{DocumentChanges: textDocumentEdits(edits)}, nil
+	}
+	return &protocol.WorkspaceEdit{Changes: edits}, nil
+}
+
+// Paths in open documents may have been broken or fixed by the rename
+func WorkspaceDidRenameFiles(ctx *glsp.Context, params *protocol.RenameFilesParams) error {
+	slog.Debug(fmt.Sprintf("WorkspaceDidRenameFiles: %d files", len(params.Files)))
+	republishDiagnostics(ctx)
+	return nil
+}
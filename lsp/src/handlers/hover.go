@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+const (
+	hoverPreviewLines   = 10
+	hoverPreviewBytes   = 4096
+	hoverListingEntries = 10
+)
+
+func TextDocumentHover(ctx *glsp.Context, params *protocol.HoverParams) (*protocol.Hover, error) {
+	slog.Debug(fmt.Sprintf("TextDocumentHover for file: %s", params.TextDocument.URI))
+
+	match, ok := pathMatchAt(params.TextDocument.URI, params.Position)
+	if !ok {
+		return nil, nil
+	}
+	absolutePaths := matchPath(match.Text, params.TextDocument.URI, "")
+	if len(absolutePaths) == 0 {
+		return nil, nil
+	}
+
+	hoverRange := match.Range(int(params.Position.Line))
+	return &protocol.Hover{
+		Contents: protocol.MarkupContent{
+			Kind:  protocol.MarkupKindMarkdown,
+			Value: documentPathMetadataMarkdown(match.Text, absolutePaths[0]),
+		},
+		Range: &hoverRange,
+	}, nil
+}
+
+// Describe resolved path with metadata and a preview of its content
+func documentPathMetadataMarkdown(inputPath string, absolutePath string) string {
+	linkInfo, err := os.Lstat(absolutePath)
+	if err != nil {
+		return documentPathMarkdown(inputPath, absolutePath)
+	}
+	fileInfo, err := os.Stat(absolutePath)
+	if err != nil {
+		fileInfo = linkInfo
+	}
+
+	var markdown strings.Builder
+	if fileInfo.IsDir() {
+		markdown.WriteString("**📂 Folder**\n")
+	} else {
+		markdown.WriteString("**📄 File**\n")
+	}
+	markdown.WriteString(documentPathMarkdown(inputPath, absolutePath))
+	markdown.WriteString("\n\n| | |\n|---|---|\n")
+	if !fileInfo.IsDir() {
+		fmt.Fprintf(&markdown, "| Size | %s |\n", formatSize(fileInfo.Size()))
+	}
+	fmt.Fprintf(&markdown, "| Modified | %s |\n", fileInfo.ModTime().Format("2006-01-02 15:04:05"))
+	fmt.Fprintf(&markdown, "| Permissions | `%s` |\n", fileInfo.Mode())
+	if linkInfo.Mode()&os.ModeSymlink != 0 {
+		if target, err := os.Readlink(absolutePath); err == nil {
+			fmt.Fprintf(&markdown, "| Symlink | `%s` |\n", target)
+		}
+	}
+
+	// Reading devices, FIFOs or sockets like "/dev/stdin" can block, so only their metadata is shown
+	if fileInfo.IsDir() {
+		markdown.WriteString(directoryListingMarkdown(absolutePath))
+	} else if fileInfo.Mode().IsRegular() {
+		markdown.WriteString(filePreviewMarkdown(absolutePath))
+	}
+	return markdown.String()
+}
+
+// Summarise folder content with counts and the first few entries
+func directoryListingMarkdown(absolutePath string) string {
+	entries, err := os.ReadDir(absolutePath)
+	if err != nil {
+		return ""
+	}
+
+	folders, files := 0, 0
+	listing := []string{}
+	for _, entry := range entries {
+		icon := "📄"
+		if entry.IsDir() {
+			icon = "📂"
+			folders++
+		} else {
+			files++
+		}
+		if len(listing) < hoverListingEntries {
+			listing = append(listing, fmt.Sprintf("- %s %s", icon, entry.Name()))
+		}
+	}
+	if len(entries) > hoverListingEntries {
+		listing = append(listing, fmt.Sprintf("- … %d more", len(entries)-hoverListingEntries))
+	}
+	return fmt.Sprintf("\n**Contents:** %d folders, %d files\n\n%s\n", folders, files, strings.Join(listing, "\n"))
+}
+
+// Show image dimensions, or the first lines of text files
+func filePreviewMarkdown(absolutePath string) string {
+	file, err := os.Open(absolutePath)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	if config, format, err := image.DecodeConfig(file); err == nil {
+		return fmt.Sprintf("\n**Image:** %s, %d × %d px\n", format, config.Width, config.Height)
+	}
+
+	if _, err := file.Seek(0, 0); err != nil {
+		return ""
+	}
+	head := make([]byte, hoverPreviewBytes)
+	n, _ := file.Read(head)
+	head = head[:n]
+	if n == 0 || isBinary(head) {
+		return ""
+	}
+
+	lines := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(head))
+	for scanner.Scan() && len(lines) < hoverPreviewLines {
+		lines = append(lines, scanner.Text())
+	}
+	fence := "```"
+	for strings.Contains(string(head), fence) {
+		fence += "`"
+	}
+	language := strings.TrimPrefix(filepath.Ext(absolutePath), ".")
+	return fmt.Sprintf("\n**Preview:**\n\n%s%s\n%s\n%s\n", fence, language, strings.Join(lines, "\n"), fence)
+}
+
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
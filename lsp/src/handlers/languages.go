@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Rules for paths written without file extension, e.g. "./utils/format"
+type languageRules struct {
+	Extensions []string `json:"extensions"` // Probed in order when the path itself does not exist
+	IndexFiles []string `json:"indexFiles"` // Entry file of a directory
+}
+
+var jsRules = languageRules{
+	Extensions: []string{".js", ".jsx", ".mjs", ".cjs", ".json"},
+	IndexFiles: []string{"index.js", "index.jsx", "index.mjs", "index.cjs"},
+}
+
+var tsRules = languageRules{
+	Extensions: []string{".ts", ".tsx", ".d.ts", ".mts", ".cts", ".js", ".jsx", ".mjs", ".cjs", ".json"},
+	IndexFiles: []string{"index.ts", "index.tsx", "index.d.ts", "index.js", "index.jsx"},
+}
+
+// Default probe rules keyed by LSP language identifier, overridable through settings
+var defaultLanguageRules = map[string]languageRules{
+	"javascript":      jsRules,
+	"javascriptreact": jsRules,
+	"typescript":      tsRules,
+	"typescriptreact": tsRules,
+	"vue":             tsRules,
+	"svelte":          tsRules,
+	"astro":           tsRules,
+	"python": {
+		Extensions: []string{".py", ".pyi"},
+		IndexFiles: []string{"__init__.py", "__init__.pyi"},
+	},
+	"go": {
+		Extensions: []string{".go"},
+	},
+	"rust": {
+		Extensions: []string{".rs"},
+		IndexFiles: []string{"mod.rs", "lib.rs"},
+	},
+}
+
+func languageRulesFor(languageID string) languageRules {
+	return currentSettings().LanguageRules[languageID]
+}
+
+// Fallback language identifiers for files that are not open
+var extensionLanguageIDs = map[string]string{
+	".js":     "javascript",
+	".mjs":    "javascript",
+	".cjs":    "javascript",
+	".jsx":    "javascriptreact",
+	".ts":     "typescript",
+	".mts":    "typescript",
+	".cts":    "typescript",
+	".tsx":    "typescriptreact",
+	".vue":    "vue",
+	".svelte": "svelte",
+	".astro":  "astro",
+	".py":     "python",
+	".go":     "go",
+	".rs":     "rust",
+	".md":     "markdown",
+	".json":   "json",
+}
+
+// Language of an open file, or guessed from its extension
+func languageIDOf(fileUri string) string {
+	if currentFile, ok := currentFiles.Get(fileUri); ok && currentFile.LanguageID != "" {
+		return currentFile.LanguageID
+	}
+	return extensionLanguageIDs[filepath.Ext(fileUri)]
+}
+
+// Strip the longest probe extension so completions offer "format" for "format.ts"
+func trimProbeExtension(name string, languageID string) string {
+	longest := ""
+	for _, extension := range languageRulesFor(languageID).Extensions {
+		if strings.HasSuffix(name, extension) && len(extension) > len(longest) && len(extension) < len(name) {
+			longest = extension
+		}
+	}
+	return strings.TrimSuffix(name, longest)
+}
+
+// Find the index file of a directory, e.g. "index.ts" or "__init__.py"
+func probeIndexFile(dir string, languageID string) (string, bool) {
+	for _, indexFile := range languageRulesFor(languageID).IndexFiles {
+		indexPath := filepath.Join(dir, indexFile)
+		if fileInfo, err := os.Stat(indexPath); err == nil && !fileInfo.IsDir() {
+			return indexPath, true
+		}
+	}
+	return "", false
+}
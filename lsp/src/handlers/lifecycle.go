@@ -5,10 +5,59 @@ import (
 	"log/slog"
 	"path-intellisense-lsp/src/glsp"
 	protocol "path-intellisense-lsp/src/protocol_3_16"
+	protocol317 "path-intellisense-lsp/src/protocol_3_17"
+	"slices"
 )
 
+var (
+	clientCapabilities    protocol.ClientCapabilities
+	clientCapabilities317 protocol317.ClientCapabilities // Only read for capabilities added in 3.17
+)
+
+// Remember client capabilities from initialize
+func SetClientCapabilities(capabilities protocol.ClientCapabilities, capabilities317 protocol317.ClientCapabilities) {
+	clientCapabilities = capabilities
+	clientCapabilities317 = capabilities317
+}
+
+func clientSupportsConfiguration() bool {
+	workspace := clientCapabilities.Workspace
+	return workspace != nil && workspace.Configuration != nil && *workspace.Configuration
+}
+
+func clientSupportsResourceOperation(kind protocol.ResourceOperationKind) bool {
+	workspace := clientCapabilities.Workspace
+	if workspace == nil || workspace.WorkspaceEdit == nil {
+		return false
+	}
+	return slices.Contains(workspace.WorkspaceEdit.ResourceOperations, kind)
+}
+
+// Clients pulling diagnostics through textDocument/diagnostic get no published diagnostics
+func clientSupportsPullDiagnostics() bool {
+	textDocument := clientCapabilities317.TextDocument
+	return textDocument != nil && textDocument.Diagnostic != nil
+}
+
+func clientSupportsDiagnosticsRefresh() bool {
+	workspace := clientCapabilities317.Workspace
+	return workspace != nil && workspace.Diagnostics != nil && workspace.Diagnostics.RefreshSupport != nil && *workspace.Diagnostics.RefreshSupport
+}
+
+func clientSupportsWatchedFilesRegistration() bool {
+	workspace := clientCapabilities.Workspace
+	return workspace != nil && workspace.DidChangeWatchedFiles != nil && workspace.DidChangeWatchedFiles.DynamicRegistration != nil && *workspace.DidChangeWatchedFiles.DynamicRegistration
+}
+
 func Initialized(ctx *glsp.Context, params *protocol.InitializedParams) error {
 	slog.Debug("Initialized server")
+	go pullSettings(ctx)
+	if clientSupportsWatchedFilesRegistration() {
+		go registerFileWatchers(ctx)
+	} else if currentSettings().NativeWatcher {
+		startNativeWatcher(ctx)
+	}
+	indexWorkspaceFolders()
 	return nil
 }
 
@@ -35,8 +84,13 @@ func LogTrace(ctx *glsp.Context, params *protocol.LogTraceParams) error {
 	return nil
 }
 
+// Stop background work, the server answered every other request already
 func Shutdown(ctx *glsp.Context) error {
 	slog.Warn("Shutdown server")
+	stopNativeWatcher()
+	// The index worker schedules diagnostics, so it stops before they are cancelled
+	stopIndexWorker()
+	cancelAllDiagnostics()
 	protocol.SetTraceValue(protocol.TraceValueOff)
 	return nil
 }
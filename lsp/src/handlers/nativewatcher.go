@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Events arriving within this delay of the first one are applied together
+const nativeWatcherCoalesceDelay = 100 * time.Millisecond
+
+// Platform watcher reporting changes of entries in watched directories
+type dirWatcher interface {
+	// Watch direct children of dir
+	Add(dir string) error
+	// Stop watching, closing the events channel
+	Close() error
+}
+
+var (
+	nativeWatcher     dirWatcher // Running watcher, nil when the client reports file changes
+	nativeWatchedDirs = map[string]bool{}
+	nativeWatchMissed bool // A directory of the workspace index couldn't be watched
+	nativeWatcherLock sync.Mutex
+)
+
+// Watch directories resolved into by the server itself, for clients without didChangeWatchedFiles
+func startNativeWatcher(ctx *glsp.Context) {
+	events := make(chan protocol.FileEvent, 256)
+	watcher, err := newDirWatcher(events)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Native file watcher unavailable: %s", err))
+		return
+	}
+
+	nativeWatcherLock.Lock()
+	nativeWatcher = watcher
+	nativeWatchedDirs = map[string]bool{}
+	nativeWatchMissed = false
+	nativeWatcherLock.Unlock()
+	slog.Debug("Started native file watcher")
+
+	go coalesceFileEvents(ctx, events)
+}
+
+func stopNativeWatcher() {
+	nativeWatcherLock.Lock()
+	defer nativeWatcherLock.Unlock()
+	if nativeWatcher == nil {
+		return
+	}
+	if err := nativeWatcher.Close(); err != nil {
+		slog.Warn(fmt.Sprintf("Failed to stop native file watcher: %s", err))
+	}
+	nativeWatcher = nil
+	slog.Debug("Stopped native file watcher")
+}
+
+// Watch a directory paths were resolved into, or its nearest existing parent while it is missing
+func watchResolvedDir(dir string) {
+	nativeWatcherLock.Lock()
+	defer nativeWatcherLock.Unlock()
+	if nativeWatcher == nil {
+		return
+	}
+	for ; !nativeWatchedDirs[dir]; dir = filepath.Dir(dir) {
+		if err := nativeWatcher.Add(dir); err == nil {
+			nativeWatchedDirs[dir] = true
+			return
+		} else if !os.IsNotExist(err) {
+			slog.Debug(fmt.Sprintf("Failed to watch %s: %s", dir, err))
+			return
+		}
+		if dir == filepath.Dir(dir) {
+			return
+		}
+	}
+}
+
+// Watch a directory of the workspace index before its entries are read, so later changes reach the index
+func watchIndexedDir(dir string) {
+	nativeWatcherLock.Lock()
+	defer nativeWatcherLock.Unlock()
+	if nativeWatcher == nil || nativeWatchedDirs[dir] {
+		return
+	}
+	if err := nativeWatcher.Add(dir); err != nil {
+		slog.Debug(fmt.Sprintf("Failed to watch %s: %s", dir, err))
+		nativeWatchMissed = true
+		return
+	}
+	nativeWatchedDirs[dir] = true
+}
+
+// Check whether the native watcher watches every directory of the workspace index
+func nativeWatcherCoversIndex() bool {
+	nativeWatcherLock.Lock()
+	defer nativeWatcherLock.Unlock()
+	return nativeWatcher != nil && !nativeWatchMissed
+}
+
+// Forget a directory the platform stopped watching, e.g. because it was deleted
+func unwatchedDir(dir string) {
+	nativeWatcherLock.Lock()
+	defer nativeWatcherLock.Unlock()
+	delete(nativeWatchedDirs, dir)
+}
+
+// Collect bursts of events, keeping the last change per file, then apply them at once
+func coalesceFileEvents(ctx *glsp.Context, events <-chan protocol.FileEvent) {
+	pending := []protocol.FileEvent{}
+	timer := time.NewTimer(nativeWatcherCoalesceDelay)
+	timer.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				timer.Stop()
+				return
+			}
+			if len(pending) == 0 {
+				timer.Reset(nativeWatcherCoalesceDelay)
+			}
+			for i, change := range pending {
+				if change.URI == event.URI {
+					// Writing a new file still creates it
+					if change.Type == protocol.FileChangeTypeCreated && event.Type == protocol.FileChangeTypeChanged {
+						event.Type = protocol.FileChangeTypeCreated
+					}
+					pending = append(pending[:i], pending[i+1:]...)
+					break
+				}
+			}
+			pending = append(pending, event)
+
+		case <-timer.C:
+			slog.Debug(fmt.Sprintf("Native file watcher: %d changes", len(pending)))
+			applyFileChanges(ctx, pending)
+			pending = []protocol.FileEvent{}
+		}
+	}
+}
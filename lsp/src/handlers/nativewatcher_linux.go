@@ -0,0 +1,99 @@
+//go:build linux
+
+package handlers
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_CLOSE_WRITE | syscall.IN_DELETE_SELF | syscall.IN_ONLYDIR
+
+type inotifyWatcher struct {
+	fd     int
+	file   *os.File // Wraps fd for reads, calling Fd on it would make it blocking
+	events chan<- protocol.FileEvent
+	dirs   map[int32]string // Watch descriptor -> directory
+	lock   sync.Mutex
+}
+
+func newDirWatcher(events chan<- protocol.FileEvent) (dirWatcher, error) {
+	// Non-blocking so reads go through the runtime poller and Close interrupts them
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	watcher := &inotifyWatcher{
+		fd:     fd,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		events: events,
+		dirs:   map[int32]string{},
+	}
+	go watcher.read()
+	return watcher, nil
+}
+
+func (w *inotifyWatcher) Add(dir string) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	w.dirs[int32(wd)] = dir
+	return nil
+}
+
+func (w *inotifyWatcher) Close() error {
+	return w.file.Close()
+}
+
+// Translate inotify events until the watcher is closed
+func (w *inotifyWatcher) read() {
+	defer close(w.events)
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buffer)
+		if err != nil {
+			return
+		}
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameBytes := buffer[offset+syscall.SizeofInotifyEvent : offset+syscall.SizeofInotifyEvent+int(event.Len)]
+			offset += syscall.SizeofInotifyEvent + int(event.Len)
+
+			w.lock.Lock()
+			dir, ok := w.dirs[event.Wd]
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, event.Wd)
+			}
+			w.lock.Unlock()
+			if !ok {
+				continue
+			}
+			if event.Mask&syscall.IN_IGNORED != 0 {
+				unwatchedDir(dir)
+				continue
+			}
+
+			path := dir
+			if name, _, _ := bytes.Cut(nameBytes, []byte{0}); len(name) > 0 {
+				path = filepath.Join(dir, string(name))
+			}
+			change := protocol.FileChangeTypeChanged
+			switch {
+			case event.Mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0:
+				change = protocol.FileChangeTypeCreated
+			case event.Mask&(syscall.IN_DELETE|syscall.IN_MOVED_FROM|syscall.IN_DELETE_SELF) != 0:
+				change = protocol.FileChangeTypeDeleted
+			}
+			w.events <- protocol.FileEvent{URI: "file://" + path, Type: change}
+		}
+	}
+}
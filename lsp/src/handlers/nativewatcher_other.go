@@ -0,0 +1,13 @@
+//go:build !linux
+
+package handlers
+
+import (
+	"errors"
+
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+func newDirWatcher(events chan<- protocol.FileEvent) (dirWatcher, error) {
+	return nil, errors.New("only supported on Linux")
+}
@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Languages whose bare specifiers resolve through node_modules
+var nodeLanguageIDs = []string{
+	"javascript", "javascriptreact", "typescript", "typescriptreact", "vue", "svelte", "astro",
+}
+
+const importSpecifierPrefix = "(from|require\\(|import\\(|import)\\s*[\"']"
+
+// Preferred "exports" conditions, in order
+var exportsConditions = []string{"types", "import", "require", "node", "default"}
+
+type packageJSON struct {
+	Main    string `json:"main"`
+	Types   string `json:"types"`
+	Typings string `json:"typings"`
+	Exports any    `json:"exports"`
+}
+
+// Split "@scope/pkg/sub" into "@scope/pkg" and "sub"
+func splitPackageSpecifier(specifier string) (string, string) {
+	segments := strings.SplitN(specifier, "/", 3)
+	if strings.HasPrefix(specifier, "@") && len(segments) > 1 {
+		name := segments[0] + "/" + segments[1]
+		if len(segments) == 3 {
+			return name, segments[2]
+		}
+		return name, ""
+	}
+	name, subpath, _ := strings.Cut(specifier, "/")
+	return name, subpath
+}
+
+// Directories named node_modules from the file's directory up to the filesystem root
+func nodeModulesDirs(fileUri string) []string {
+	dirs := []string{}
+	dir, _ := pathBaseDir(pathBaseFile, fileUri)
+	for {
+		nodeModulesDir := filepath.Join(dir, "node_modules")
+		if fileInfo, err := os.Stat(nodeModulesDir); err == nil && fileInfo.IsDir() {
+			dirs = append(dirs, nodeModulesDir)
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dirs
+		}
+		dir = parent
+	}
+}
+
+// Resolve bare specifier like "lodash/fp" or "@scope/pkg/sub" through node_modules
+func nodeModulesPathSuggestions(specifier string, fileUri string, joinPath string) []string {
+	name, subpath := splitPackageSpecifier(specifier)
+	packageDir, ok := nodePackageDir(name, fileUri)
+	if !ok {
+		return []string{}
+	}
+	// Completion lists files inside the package
+	if joinPath != "" {
+		return absolutePathSuggestions(filepath.Join(packageDir, subpath), joinPath)
+	}
+	if target, ok := resolvePackageEntry(packageDir, subpath); ok {
+		return []string{target}
+	}
+	return absolutePathSuggestions(filepath.Join(packageDir, subpath), joinPath)
+}
+
+// Find the closest package directory, walking up node_modules like Node does
+func nodePackageDir(name string, fileUri string) (string, bool) {
+	for _, nodeModulesDir := range nodeModulesDirs(fileUri) {
+		packageDir := filepath.Join(nodeModulesDir, name)
+		if fileInfo, err := os.Stat(packageDir); err == nil && fileInfo.IsDir() {
+			return packageDir, true
+		}
+	}
+	return "", false
+}
+
+// List package names in node_modules starting with prefix
+func nodePackageNames(fileUri string, prefix string) []string {
+	names := []string{}
+	for _, nodeModulesDir := range nodeModulesDirs(fileUri) {
+		entries, err := os.ReadDir(nodeModulesDir)
+		if err != nil {
+			continue
+		}
+		for _, entry := range entries {
+			name := entry.Name()
+			if strings.HasPrefix(name, ".") || !strings.HasPrefix(name, prefix) || slices.Contains(names, name) {
+				continue
+			}
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Find quoted import specifiers without "/" like "lodash", which findPathMatches skips
+func findImportSpecifierMatches(line string) []pathMatch {
+	re := mustCompileLazyRegex(importSpecifierPrefix + "([^\"'/\\s.~][^\"'/\\s]*)[\"']")
+	results := []pathMatch{}
+	for _, loc := range re.FindAllStringSubmatchIndex(line, -1) {
+		// Second capture group is the specifier
+		results = append(results, newPathMatch(line, loc[4], loc[5]))
+	}
+	return results
+}
+
+// Extract a partially typed package name from an unterminated import string
+func extractPackagePrefix(text string) (string, bool) {
+	re := mustCompileLazyRegex(importSpecifierPrefix + "(@?[^\"'/\\s.~]*)$")
+	matches := re.FindStringSubmatch(text)
+	if matches == nil {
+		return "", false
+	}
+	return matches[2], true
+}
+
+// Resolve the file a package subpath points to using "exports", "types" and "main"
+func resolvePackageEntry(packageDir string, subpath string) (string, bool) {
+	data, err := os.ReadFile(filepath.Join(packageDir, "package.json"))
+	if err != nil {
+		return "", false
+	}
+	var manifest packageJSON
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", false
+	}
+
+	if manifest.Exports != nil {
+		exportsSubpath := "./" + subpath
+		if subpath == "" {
+			exportsSubpath = "."
+		}
+		if target, ok := resolvePackageExports(manifest.Exports, exportsSubpath); ok {
+			return existingPath(filepath.Join(packageDir, target))
+		}
+	}
+
+	if subpath != "" {
+		return "", false
+	}
+	for _, entry := range []string{manifest.Types, manifest.Typings, manifest.Main, "index.js"} {
+		if entry == "" {
+			continue
+		}
+		if target, ok := existingPath(filepath.Join(packageDir, entry)); ok {
+			return target, true
+		}
+	}
+	return "", false
+}
+
+// Resolve subpath like "." or "./fp" against package.json "exports"
+func resolvePackageExports(exports any, subpath string) (string, bool) {
+	subpaths, ok := exports.(map[string]any)
+	// Exports without subpath keys only describe the "." entry
+	if !ok || !hasSubpathKeys(subpaths) {
+		if subpath != "." {
+			return "", false
+		}
+		return resolveExportsTarget(exports, "")
+	}
+
+	if target, ok := subpaths[subpath]; ok {
+		return resolveExportsTarget(target, "")
+	}
+
+	// Longest matching "./prefix*suffix" pattern wins
+	bestKey, bestWildcard := "", ""
+	for key := range subpaths {
+		prefix, suffix, hasWildcard := strings.Cut(key, "*")
+		if !hasWildcard || len(prefix) <= len(bestKey) {
+			continue
+		}
+		if strings.HasPrefix(subpath, prefix) && strings.HasSuffix(subpath, suffix) && len(subpath) >= len(prefix)+len(suffix) {
+			bestKey, bestWildcard = key, subpath[len(prefix):len(subpath)-len(suffix)]
+		}
+	}
+	if bestKey == "" {
+		return "", false
+	}
+	return resolveExportsTarget(subpaths[bestKey], bestWildcard)
+}
+
+func hasSubpathKeys(exports map[string]any) bool {
+	for key := range exports {
+		if strings.HasPrefix(key, ".") {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve conditional, array or string export targets, substituting "*" with wildcard
+func resolveExportsTarget(target any, wildcard string) (string, bool) {
+	switch v := target.(type) {
+	case string:
+		return strings.ReplaceAll(v, "*", wildcard), true
+	case []any:
+		for _, item := range v {
+			if resolved, ok := resolveExportsTarget(item, wildcard); ok {
+				return resolved, true
+			}
+		}
+	case map[string]any:
+		for _, condition := range exportsConditions {
+			if item, ok := v[condition]; ok {
+				if resolved, ok := resolveExportsTarget(item, wildcard); ok {
+					return resolved, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+func existingPath(path string) (string, bool) {
+	if _, err := os.Stat(path); err != nil {
+		return "", false
+	}
+	return path, true
+}
+
+func documentPackageMarkdown(name string, packageDir string) string {
+	return fmt.Sprintf(`
+**Package:**
+
+*%s*
+
+**Absolute path:**
+
+[*%s*](file://%s)`,
+		name, packageDir, packageDir)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"slices"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+	protocol317 "path-intellisense-lsp/src/protocol_3_17"
+)
+
+// Diagnostics of an open document, unchanged when they match what the client last pulled
+func TextDocumentDiagnostic(ctx *glsp.Context, params *protocol317.DocumentDiagnosticParams) (any, error) {
+	slog.Debug(fmt.Sprintf("TextDocumentDiagnostic for file: %s", params.TextDocument.URI))
+
+	currentFile, err := currentFiles.Require(params.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+	diagnostics, err := pathDiagnostics(requestContext(ctx), currentFile.diagnosticsParams())
+	if err != nil {
+		return nil, err
+	}
+
+	resultID := diagnosticsResultID(diagnostics)
+	if params.PreviousResultId != nil && *params.PreviousResultId == resultID {
+		return protocol317.RelatedUnchangedDocumentDiagnosticReport{
+			UnchangedDocumentDiagnosticReport: protocol317.UnchangedDocumentDiagnosticReport{
+				Kind:     string(protocol317.DocumentDiagnosticReportKindUnchanged),
+				ResultID: resultID,
+			},
+		}, nil
+	}
+	return protocol317.RelatedFullDocumentDiagnosticReport{
+		FullDocumentDiagnosticReport: protocol317.FullDocumentDiagnosticReport{
+			Kind:     string(protocol317.DocumentDiagnosticReportKindFull),
+			ResultID: &resultID,
+			Items:    diagnostics,
+		},
+	}, nil
+}
+
+// Diagnostics of workspace files that are not open, open documents are pulled one by one.
+// Files without diagnostics are only reported to clear diagnostics the client pulled before.
+func WorkspaceDiagnostic(ctx *glsp.Context, params *protocol317.WorkspaceDiagnosticParams) (*protocol317.WorkspaceDiagnosticReport, error) {
+	slog.Debug(fmt.Sprintf("WorkspaceDiagnostic with %d previous results", len(params.PreviousResultIds)))
+
+	previousResultIDs := map[string]string{}
+	for _, previous := range params.PreviousResultIds {
+		previousResultIDs[previous.URI] = previous.Value
+	}
+
+	report := &protocol317.WorkspaceDiagnosticReport{Items: []protocol317.WorkspaceDocumentDiagnosticReport{}}
+	uris := workspaceFileUris()
+	for uri := range previousResultIDs {
+		if !slices.Contains(uris, uri) {
+			uris = append(uris, uri)
+		}
+	}
+	for _, uri := range uris {
+		if _, open := currentFiles.Get(uri); open {
+			continue
+		}
+		diagnostics, err := fileDiagnostics(requestContext(ctx), uri)
+		if err != nil {
+			return nil, err
+		}
+		previousResultID, pulled := previousResultIDs[uri]
+		if len(diagnostics) == 0 && !pulled {
+			continue
+		}
+
+		resultID := diagnosticsResultID(diagnostics)
+		if pulled && previousResultID == resultID {
+			report.Items = append(report.Items, protocol317.WorkspaceUnchangedDocumentDiagnosticReport{
+				UnchangedDocumentDiagnosticReport: protocol317.UnchangedDocumentDiagnosticReport{
+					Kind:     string(protocol317.DocumentDiagnosticReportKindUnchanged),
+					ResultID: resultID,
+				},
+				URI: uri,
+			})
+			continue
+		}
+		report.Items = append(report.Items, protocol317.WorkspaceFullDocumentDiagnosticReport{
+			FullDocumentDiagnosticReport: protocol317.FullDocumentDiagnosticReport{
+				Kind:     string(protocol317.DocumentDiagnosticReportKindFull),
+				ResultID: &resultID,
+				Items:    diagnostics,
+			},
+			URI: uri,
+		})
+	}
+	return report, nil
+}
+
+// Diagnostics of a file on disk. Files the index found no unresolved paths in are not read.
+func fileDiagnostics(ctx context.Context, fileUri string) ([]protocol.Diagnostic, error) {
+	if references, ok := indexedFileReferences(fileUri); ok && !slices.ContainsFunc(references, func(reference pathReference) bool {
+		return reference.AbsolutePath == ""
+	}) {
+		return []protocol.Diagnostic{}, nil
+	}
+	text, ok := readFileText(uriPath(fileUri))
+	if !ok {
+		return []protocol.Diagnostic{}, nil
+	}
+	return pathDiagnostics(ctx, &textDocumentPublishDiagnosticsParams{
+		URI:        fileUri,
+		LanguageID: languageIDOf(fileUri),
+		content:    newRope(text),
+	})
+}
+
+// Result id identifying a set of diagnostics, equal ids mean the client's copy is still accurate
+func diagnosticsResultID(diagnostics []protocol.Diagnostic) string {
+	data, err := json.Marshal(diagnostics)
+	if err != nil {
+		return ""
+	}
+	hash := fnv.New64a()
+	hash.Write(data)
+	return fmt.Sprintf("%x", hash.Sum64())
+}
+
+// Ask clients pulling diagnostics to pull again, e.g. after files changed on disk.
+func refreshDiagnostics(ctx *glsp.Context) {
+	if !clientSupportsDiagnosticsRefresh() {
+		return
+	}
+	ctx.Call(protocol317.ServerWorkspaceDiagnosticRefresh, nil, nil)
+	slog.Debug("Requested diagnostics refresh")
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Find every path string in the workspace resolving to the file under the cursor.
+// Outside of a path string the document itself is the target.
+func TextDocumentReferences(ctx *glsp.Context, params *protocol.ReferenceParams) ([]protocol.Location, error) {
+	slog.Debug(fmt.Sprintf("TextDocumentReferences for file: %s", params.TextDocument.URI))
+
+	targetPath := uriPath(params.TextDocument.URI)
+	if match, ok := pathMatchAt(params.TextDocument.URI, params.Position); ok {
+		if absolutePaths := matchPath(match.Text, params.TextDocument.URI, ""); len(absolutePaths) > 0 {
+			targetPath = absolutePaths[0]
+		}
+	}
+
+	locations := []protocol.Location{}
+	if params.Context.IncludeDeclaration {
+		locations = append(locations, protocol.Location{URI: "file://" + targetPath})
+	}
+	references := workspacePathReferences(func(absolutePath string) bool {
+		return absolutePath == targetPath
+	})
+	for _, reference := range references {
+		locations = append(locations, protocol.Location{
+			URI:   reference.URI,
+			Range: reference.Match.Range(reference.Line),
+		})
+	}
+	return locations, nil
+}
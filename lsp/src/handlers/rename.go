@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+func TextDocumentPrepareRename(ctx *glsp.Context, params *protocol.PrepareRenameParams) (any, error) {
+	slog.Debug(fmt.Sprintf("TextDocumentPrepareRename for file: %s", params.TextDocument.URI))
+
+	match, ok := pathMatchAt(params.TextDocument.URI, params.Position)
+	if !ok || len(matchPath(match.Text, params.TextDocument.URI, "")) == 0 {
+		return nil, nil
+	}
+	return protocol.RangeWithPlaceholder{
+		Range:       match.Range(int(params.Position.Line)),
+		Placeholder: match.Text,
+	}, nil
+}
+
+// Rename the file a path string points to, interpreting the new name as the new path text
+func TextDocumentRename(ctx *glsp.Context, params *protocol.RenameParams) (*protocol.WorkspaceEdit, error) {
+	slog.Debug(fmt.Sprintf("TextDocumentRename for file: %s", params.TextDocument.URI))
+
+	if !clientSupportsDocumentChanges() || !clientSupportsResourceOperation(protocol.ResourceOperationKindRename) {
+		return nil, errors.New("client does not support renaming files")
+	}
+	if strings.TrimSpace(params.NewName) == "" {
+		return nil, errors.New("new path must not be empty")
+	}
+	match, ok := pathMatchAt(params.TextDocument.URI, params.Position)
+	if !ok {
+		return nil, errors.New("no path at position")
+	}
+	absolutePaths := matchPath(match.Text, params.TextDocument.URI, "")
+	if len(absolutePaths) == 0 {
+		return nil, fmt.Errorf("path not found: %s", match.Text)
+	}
+	oldAbsolutePath := absolutePaths[0]
+
+	newAbsolutePath, ok := intendedAbsolutePath(params.NewName, params.TextDocument.URI)
+	if !ok {
+		return nil, fmt.Errorf("cannot resolve new path: %s", params.NewName)
+	}
+	// Keep the probed extension when the path omits it, e.g. "./utils/format" for "format.ts"
+	if extension, ok := omittedExtension(match.Text, oldAbsolutePath); ok && filepath.Ext(params.NewName) == "" {
+		newAbsolutePath += extension
+	}
+	if _, err := os.Stat(newAbsolutePath); err == nil {
+		return nil, fmt.Errorf("path already exists: %s", newAbsolutePath)
+	}
+
+	return renamePathEdit(oldAbsolutePath, newAbsolutePath), nil
+}
+
+func clientSupportsDocumentChanges() bool {
+	workspace := clientCapabilities.Workspace
+	return workspace != nil && workspace.WorkspaceEdit != nil && workspace.WorkspaceEdit.DocumentChanges != nil && *workspace.WorkspaceEdit.DocumentChanges
+}
+
+// A file or folder moving from Old to New
+type pathMove struct {
+	Old string
+	New string
+}
+
+// Edit moving a file or folder and rewriting every path string in the workspace that refers into it
+func renamePathEdit(oldAbsolutePath string, newAbsolutePath string) *protocol.WorkspaceEdit {
+	edits := pathRenameTextEdits([]pathMove{{Old: oldAbsolutePath, New: newAbsolutePath}})
+
+	// Text edits address documents at their old location, so they go before the rename
+	documentChanges := textDocumentEdits(edits)
+	documentChanges = append(documentChanges, protocol.RenameFile{
+		Kind:   "rename",
+		OldURI: "file://" + oldAbsolutePath,
+		NewURI: "file://" + newAbsolutePath,
+	})
+	return &protocol.WorkspaceEdit{DocumentChanges: documentChanges}
+}
+
+// Versioned TextDocumentEdit per document, sorted by URI
+func textDocumentEdits(edits map[string][]protocol.TextEdit) []any {
+	uris := make([]string, 0, len(edits))
+	for uri := range edits {
+		uris = append(uris, uri)
+	}
+	slices.Sort(uris)
+
+	documentChanges := []any{}
+	for _, uri := range uris {
+		textEdits := []any{}
+		for _, edit := range edits[uri] {
+			textEdits = append(textEdits, edit)
+		}
+		var version *protocol.Integer
+		if currentFile, ok := currentFiles.Get(uri); ok {
+			version = &currentFile.Version
+		}
+		documentChanges = append(documentChanges, protocol.TextDocumentEdit{
+			TextDocument: protocol.OptionalVersionedTextDocumentIdentifier{
+				TextDocumentIdentifier: protocol.TextDocumentIdentifier{URI: uri},
+				Version:                version,
+			},
+			Edits: textEdits,
+		})
+	}
+	return documentChanges
+}
+
+// Text edits for references into moved paths, and relative references inside moved files
+func pathRenameTextEdits(moves []pathMove) map[string][]protocol.TextEdit {
+	edits := map[string][]protocol.TextEdit{}
+	for _, uri := range workspaceFileUris() {
+		filePath := uriPath(uri)
+		newFilePath, fileMoved := movedPath(filePath, moves)
+		references := filePathReferences(uri, func(absolutePath string) bool {
+			_, targetMoved := movedPath(absolutePath, moves)
+			return fileMoved || targetMoved
+		})
+
+		for _, reference := range references {
+			newTarget, _ := movedPath(reference.AbsolutePath, moves)
+			newText := rewritePathText(reference.Match.Text, reference.AbsolutePath, newTarget, filepath.Dir(newFilePath))
+			if newText == reference.Match.Text {
+				continue
+			}
+			edits[uri] = append(edits[uri], protocol.TextEdit{
+				Range:   reference.Match.Range(reference.Line),
+				NewText: newText,
+			})
+		}
+	}
+	return edits
+}
+
+// New location of path after applying the first move containing it
+func movedPath(path string, moves []pathMove) (string, bool) {
+	for _, move := range moves {
+		if path == move.Old {
+			return move.New, true
+		}
+		if isWithinDir(path, move.Old) {
+			rel, _ := filepath.Rel(move.Old, path)
+			return filepath.Join(move.New, rel), true
+		}
+	}
+	return path, false
+}
+
+// Extension of the resolved file that the path text leaves out
+func omittedExtension(text string, absolutePath string) (string, bool) {
+	name := filepath.Base(strings.TrimSuffix(text, "/"))
+	resolvedName := filepath.Base(absolutePath)
+	if name != resolvedName && strings.HasPrefix(resolvedName, name+".") {
+		return resolvedName[len(name):], true
+	}
+	return "", false
+}
+
+// Rewrite path text to point at newTarget, preserving its form where possible.
+// Relative paths are recomputed from the referring file's directory, other forms keep
+// their prefix (alias, workspace root, home) and only change the segments that moved.
+func rewritePathText(text string, oldTarget string, newTarget string, newRefDir string) string {
+	trailingSlash := ""
+	if strings.HasSuffix(text, "/") && len(text) > 1 {
+		trailingSlash = "/"
+	}
+	trimmed := strings.TrimSuffix(text, "/")
+
+	// Refer to targets without the extension the text omits
+	if extension, ok := omittedExtension(text, oldTarget); ok {
+		oldTarget = strings.TrimSuffix(oldTarget, extension)
+		newTarget = strings.TrimSuffix(newTarget, extension)
+	}
+
+	relativeText := func() string {
+		rel, err := filepath.Rel(newRefDir, newTarget)
+		if err != nil {
+			return text
+		}
+		if rel != "." && rel != ".." && !strings.HasPrefix(rel, "../") {
+			rel = "./" + rel
+		}
+		return rel + trailingSlash
+	}
+
+	if trimmed == "." || trimmed == ".." || strings.HasPrefix(trimmed, "./") || strings.HasPrefix(trimmed, "../") {
+		return relativeText()
+	}
+	if oldTarget == newTarget {
+		return text
+	}
+
+	// Find the shortest matching tail whose remaining directory still contains the new target
+	segments := strings.Split(trimmed, "/")
+	targetSegments := strings.Split(oldTarget, "/")
+	for k := 1; k <= len(segments) && k <= len(targetSegments); k++ {
+		if segments[len(segments)-k] != targetSegments[len(targetSegments)-k] {
+			break
+		}
+		dir := "/" + filepath.Join(targetSegments[:len(targetSegments)-k]...)
+		if newTarget != dir && !isWithinDir(newTarget, dir) {
+			continue
+		}
+		rel, err := filepath.Rel(dir, newTarget)
+		if err != nil {
+			break
+		}
+		prefix := segments[:len(segments)-k]
+		if len(prefix) == 0 {
+			return rel + trailingSlash
+		}
+		return strings.Join(prefix, "/") + "/" + rel + trailingSlash
+	}
+	return relativeText()
+}
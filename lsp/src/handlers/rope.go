@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+)
+
+// Text longer than this is split into several nodes when inserted
+const ropeChunkSize = 1024
+
+// Persistent rope: a treap of text chunks ordered by position, with byte and newline
+// counts per subtree so edits and line lookups take O(log n). Edits copy the path they
+// touch and never modify existing nodes, so older versions stay valid as snapshots.
+type rope struct {
+	root *ropeNode
+}
+
+type ropeNode struct {
+	left     *ropeNode
+	right    *ropeNode
+	priority uint32
+	chunk    string
+	length   int // Bytes in the subtree
+	newlines int // Newlines in the subtree
+}
+
+func newRope(text string) rope {
+	return rope{root: buildRopeNodes(text)}
+}
+
+func newRopeNode(left *ropeNode, right *ropeNode, priority uint32, chunk string) *ropeNode {
+	node := &ropeNode{left: left, right: right, priority: priority, chunk: chunk}
+	node.length = len(chunk) + left.len() + right.len()
+	node.newlines = strings.Count(chunk, "\n") + left.lineBreaks() + right.lineBreaks()
+	return node
+}
+
+func (n *ropeNode) len() int {
+	if n == nil {
+		return 0
+	}
+	return n.length
+}
+
+func (n *ropeNode) lineBreaks() int {
+	if n == nil {
+		return 0
+	}
+	return n.newlines
+}
+
+// Treap of text split into chunks
+func buildRopeNodes(text string) *ropeNode {
+	var root *ropeNode
+	for len(text) > 0 {
+		size := min(len(text), ropeChunkSize)
+		root = mergeRopeNodes(root, newRopeNode(nil, nil, rand.Uint32(), text[:size]))
+		text = text[size:]
+	}
+	return root
+}
+
+// Concatenate two treaps, keeping the higher priority on top
+func mergeRopeNodes(left *ropeNode, right *ropeNode) *ropeNode {
+	if left == nil {
+		return right
+	}
+	if right == nil {
+		return left
+	}
+	if left.priority > right.priority {
+		return newRopeNode(left.left, mergeRopeNodes(left.right, right), left.priority, left.chunk)
+	}
+	return newRopeNode(mergeRopeNodes(left, right.left), right.right, right.priority, right.chunk)
+}
+
+// Split a treap into the first offset bytes and the rest
+func splitRopeNodes(node *ropeNode, offset int) (*ropeNode, *ropeNode) {
+	if node == nil {
+		return nil, nil
+	}
+	leftLength := node.left.len()
+	switch {
+	case offset <= leftLength:
+		left, right := splitRopeNodes(node.left, offset)
+		return left, newRopeNode(right, node.right, node.priority, node.chunk)
+
+	case offset >= leftLength+len(node.chunk):
+		left, right := splitRopeNodes(node.right, offset-leftLength-len(node.chunk))
+		return newRopeNode(node.left, left, node.priority, node.chunk), right
+
+	default:
+		// Split inside this node's chunk, both halves keep its priority
+		at := offset - leftLength
+		return newRopeNode(node.left, nil, node.priority, node.chunk[:at]), newRopeNode(nil, node.right, node.priority, node.chunk[at:])
+	}
+}
+
+func (r rope) Len() int {
+	return r.root.len()
+}
+
+func (r rope) LineCount() int {
+	return r.root.lineBreaks() + 1
+}
+
+// Rope with bytes [start, end) replaced by text
+func (r rope) Replace(start int, end int, text string) rope {
+	left, rest := splitRopeNodes(r.root, start)
+	_, right := splitRopeNodes(rest, end-start)
+	return rope{root: mergeRopeNodes(mergeRopeNodes(left, buildRopeNodes(text)), right)}
+}
+
+func (r rope) String() string {
+	var builder strings.Builder
+	builder.Grow(r.Len())
+	r.root.writeTo(&builder, 0, r.Len())
+	return builder.String()
+}
+
+// Lines of the text without line breaks, like textLines
+func (r rope) Lines() []string {
+	return textLines(r.String())
+}
+
+// Bytes [start, end) of the text
+func (r rope) Slice(start int, end int) string {
+	var builder strings.Builder
+	builder.Grow(end - start)
+	r.root.writeTo(&builder, start, end)
+	return builder.String()
+}
+
+// Write the part of the subtree within [start, end), relative to the subtree
+func (n *ropeNode) writeTo(builder *strings.Builder, start int, end int) {
+	if n == nil || start >= end {
+		return
+	}
+	leftLength := n.left.len()
+	if start < leftLength {
+		n.left.writeTo(builder, start, min(end, leftLength))
+	}
+	chunkStart, chunkEnd := max(start-leftLength, 0), min(end-leftLength, len(n.chunk))
+	if chunkStart < chunkEnd {
+		builder.WriteString(n.chunk[chunkStart:chunkEnd])
+	}
+	rightStart := leftLength + len(n.chunk)
+	if end > rightStart {
+		n.right.writeTo(builder, max(start-rightStart, 0), end-rightStart)
+	}
+}
+
+// Byte offset where a line starts
+func (r rope) LineStart(line int) (int, error) {
+	if line < 0 || line >= r.LineCount() {
+		return 0, fmt.Errorf("line %d out of range with %d lines", line, r.LineCount())
+	}
+	if line == 0 {
+		return 0, nil
+	}
+	// Find the line-th newline, the line starts right after it
+	offset := 0
+	node := r.root
+	for node != nil {
+		if line <= node.left.lineBreaks() {
+			node = node.left
+			continue
+		}
+		line -= node.left.lineBreaks()
+		offset += node.left.len()
+		chunkNewlines := strings.Count(node.chunk, "\n")
+		if line <= chunkNewlines {
+			index := 0
+			for ; line > 0; line-- {
+				index += strings.IndexByte(node.chunk[index:], '\n') + 1
+			}
+			return offset + index, nil
+		}
+		line -= chunkNewlines
+		offset += len(node.chunk)
+		node = node.right
+	}
+	return 0, fmt.Errorf("line %d not found", line)
+}
+
+// Text of a line without its line break
+func (r rope) Line(line int) (string, error) {
+	start, err := r.LineStart(line)
+	if err != nil {
+		return "", err
+	}
+	if line+1 == r.LineCount() {
+		return r.Slice(start, r.Len()), nil
+	}
+	end, err := r.LineStart(line + 1)
+	if err != nil {
+		return "", err
+	}
+	// Line breaks are either \n or \r\n, like textLines
+	return strings.TrimSuffix(r.Slice(start, end-1), "\r"), nil
+}
+
+// Byte offset of a protocol position
+func (r rope) Offset(line uint32, character uint32) (int, error) {
+	text, err := r.Line(int(line))
+	if err != nil {
+		return 0, err
+	}
+	start, _ := r.LineStart(int(line))
+	return start + byteOffset(text, character), nil
+}
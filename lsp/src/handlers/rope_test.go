@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Byte offset of a position in text, computed from the whole text like full sync would
+func fullTextOffset(text string, line uint32, character uint32) int {
+	start := 0
+	for range line {
+		start += strings.IndexByte(text[start:], '\n') + 1
+	}
+	return start + byteOffset(textLines(text)[line], character)
+}
+
+// Incremental changes applied to the rope must leave the same text as applying them to the whole text
+func FuzzApplyContentChanges(f *testing.F) {
+	f.Add("", []byte{0, 0, 0, 0}, "hello\nworld")
+	f.Add("first\nsecond\nthird", []byte{0, 2, 2, 3, 1, 0, 1, 6}, "X\nY|")
+	f.Add("a\r\nb\r\nc", []byte{0, 1, 1, 0, 2, 0, 2, 1}, "\r\n|z")
+	f.Add("😀a😀\n😀😀\nb😀", []byte{0, 1, 0, 3, 1, 2, 2, 1, 0, 4, 0, 4}, "😀|\n|é")
+	f.Add("x😀y\n", []byte{0, 3, 1, 0, 0, 2, 0, 2}, "|\n😀\n")
+	f.Add("line\nline\nline\nline", []byte{1, 9, 3, 0, 0, 0, 2, 99}, "a\nb\nc|")
+
+	f.Fuzz(func(t *testing.T, text string, positions []byte, inserts string) {
+		content := newRope(text)
+		insertTexts := strings.Split(inserts, "|")
+		for i := 0; i+3 < len(positions); i += 4 {
+			lineCount := uint32(len(textLines(text)))
+			start := protocol.Position{Line: uint32(positions[i]) % lineCount, Character: uint32(positions[i+1])}
+			end := protocol.Position{Line: uint32(positions[i+2]) % lineCount, Character: uint32(positions[i+3])}
+			startOffset, endOffset := fullTextOffset(text, start.Line, start.Character), fullTextOffset(text, end.Line, end.Character)
+			if endOffset < startOffset {
+				start, end = end, start
+				startOffset, endOffset = endOffset, startOffset
+			}
+			insert := insertTexts[(i/4)%len(insertTexts)]
+
+			var err error
+			content, _, err = applyContentChanges(content, []any{protocol.TextDocumentContentChangeEvent{
+				Range: &protocol.Range{Start: start, End: end},
+				Text:  insert,
+			}})
+			if err != nil {
+				t.Fatalf("change %v-%v: %s", start, end, err)
+			}
+			text = text[:startOffset] + insert + text[endOffset:]
+
+			if content.String() != text {
+				t.Fatalf("after change %v-%v %q: rope has %q, full text %q", start, end, insert, content.String(), text)
+			}
+			lines := textLines(text)
+			if content.LineCount() != len(lines) {
+				t.Fatalf("rope has %d lines, full text %d", content.LineCount(), len(lines))
+			}
+			for j, line := range lines {
+				if ropeLine, err := content.Line(j); err != nil || ropeLine != line {
+					t.Fatalf("line %d: rope has %q (%v), full text %q", j, ropeLine, err, line)
+				}
+			}
+		}
+	})
+}
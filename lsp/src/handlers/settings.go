@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Section requested through workspace/configuration and expected in pushed settings
+const settingsSection = "pathIntellisense"
+
+type settings struct {
+	// Only read from initializationOptions, completion options can't change after initialize
+	TriggerCharacters  []string                 `json:"triggerCharacters"`
+	IllegalCharacters  string                   `json:"illegalCharacters"`
+	ShowHiddenFiles    bool                     `json:"showHiddenFiles"`
+	JumpToIndexFile    bool                     `json:"jumpToIndexFile"` // Definition of a directory opens its index file
+	RootPathPrecedence []pathBase               `json:"rootPathPrecedence"`
+	BarePathPrecedence []pathBase               `json:"barePathPrecedence"`
+	LanguageRules      map[string]languageRules `json:"languageRules"`
+	Diagnostics        diagnosticsSettings      `json:"diagnostics"`
+	NativeWatcher      bool                     `json:"nativeWatcher"` // Watch resolved directories when the client doesn't report file changes
+}
+
+type diagnosticsSettings struct {
+	Enable   bool   `json:"enable"`
+	Severity string `json:"severity"` // "error" | "warning" | "information" | "hint"
+	Delay    int    `json:"delay"`    // Milliseconds to wait after a change before computing diagnostics
+}
+
+func defaultSettings() settings {
+	return settings{
+		TriggerCharacters:  []string{"/"},
+		IllegalCharacters:  "/:?\"<>|\r\n &",
+		ShowHiddenFiles:    true,
+		JumpToIndexFile:    true,
+		RootPathPrecedence: []pathBase{pathBaseFilesystem, pathBaseWorkspace},
+		BarePathPrecedence: []pathBase{pathBaseFile, pathBaseWorkspace},
+		LanguageRules:      maps.Clone(defaultLanguageRules),
+		Diagnostics: diagnosticsSettings{
+			Enable:   true,
+			Severity: "error",
+			Delay:    200,
+		},
+		NativeWatcher: true,
+	}
+}
+
+var (
+	baseSettingsValue     = defaultSettings() // Defaults with the settings file applied, clients override them
+	currentSettingsValue  = defaultSettings()
+	settingsLock          sync.RWMutex
+	supportsConfiguration bool
+)
+
+// Copy of the base settings sharing no slices or maps, so unmarshalling into it leaves them intact
+func baseSettings() settings {
+	settingsLock.RLock()
+	defer settingsLock.RUnlock()
+	base := baseSettingsValue
+	base.TriggerCharacters = slices.Clone(base.TriggerCharacters)
+	base.RootPathPrecedence = slices.Clone(base.RootPathPrecedence)
+	base.BarePathPrecedence = slices.Clone(base.BarePathPrecedence)
+	base.LanguageRules = maps.Clone(base.LanguageRules)
+	return base
+}
+
+// Read settings shaped like initializationOptions from a JSON file, before any client connects.
+// They replace the defaults, so settings sent by clients only override the fields they set.
+func LoadSettingsFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var raw any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("invalid settings file %s: %w", path, err)
+	}
+	newSettings, err := parseSettings(raw)
+	if err != nil {
+		return fmt.Errorf("invalid settings file %s: %w", path, err)
+	}
+
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+	baseSettingsValue = newSettings
+	currentSettingsValue = newSettings
+	return nil
+}
+
+func currentSettings() settings {
+	settingsLock.RLock()
+	defer settingsLock.RUnlock()
+	return currentSettingsValue
+}
+
+// Completion trigger characters, fixed after initialize
+func TriggerCharacters() []string {
+	return slices.Clone(currentSettings().TriggerCharacters)
+}
+
+// Apply InitializeParams.InitializationOptions
+func ApplyInitializationOptions(params *protocol.InitializeParams) {
+	if params.InitializationOptions == nil {
+		return
+	}
+	newSettings, err := parseSettings(params.InitializationOptions)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Invalid initializationOptions: %s", err))
+		return
+	}
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+	currentSettingsValue = newSettings
+}
+
+func WorkspaceDidChangeConfiguration(ctx *glsp.Context, params *protocol.DidChangeConfigurationParams) error {
+	// Pushed settings without our section mean the client expects a pull
+	if section, ok := settingsSectionOf(params.Settings); ok {
+		if err := applySettings(section); err != nil {
+			return err
+		}
+		republishDiagnostics(ctx)
+		return nil
+	}
+	go pullSettings(ctx)
+	return nil
+}
+
+// Request settings through workspace/configuration.
+func pullSettings(ctx *glsp.Context) {
+	if !clientSupportsConfiguration() {
+		return
+	}
+	section := settingsSection
+	var result []any
+	ctx.Call(protocol.ServerWorkspaceConfiguration, &protocol.ConfigurationParams{
+		Items: []protocol.ConfigurationItem{{Section: &section}},
+	}, &result)
+	if len(result) == 0 || result[0] == nil {
+		return
+	}
+	if err := applySettings(result[0]); err != nil {
+		slog.Warn(fmt.Sprintf("Invalid workspace configuration: %s", err))
+		return
+	}
+	republishDiagnostics(ctx)
+}
+
+// Merge raw settings over the base settings, unset fields keep their base value
+func parseSettings(raw any) (settings, error) {
+	if section, ok := settingsSectionOf(raw); ok {
+		raw = section
+	}
+	newSettings := baseSettings()
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return newSettings, err
+	}
+	err = json.Unmarshal(data, &newSettings)
+	return newSettings, err
+}
+
+// Replace settings after initialize
+func applySettings(raw any) error {
+	newSettings, err := parseSettings(raw)
+	if err != nil {
+		return err
+	}
+
+	settingsLock.Lock()
+	// Trigger characters were registered at initialize and can't change
+	newSettings.TriggerCharacters = currentSettingsValue.TriggerCharacters
+	currentSettingsValue = newSettings
+	settingsLock.Unlock()
+	slog.Debug(fmt.Sprintf("Settings: %+v", newSettings))
+
+	// Precedence and language rules change what indexed references resolve to
+	queueIndexUpdate("reresolve", reresolveIndexedReferences)
+	return nil
+}
+
+// Find our section in settings shaped like {"pathIntellisense": {...}}
+func settingsSectionOf(raw any) (any, bool) {
+	object, ok := raw.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	section, ok := object[settingsSection]
+	return section, ok
+}
+
+// Re-publish diagnostics of every open file, e.g. after settings change
+func republishDiagnostics(ctx *glsp.Context) {
+	invalidateDiagnostics()
+	if clientSupportsPullDiagnostics() {
+		go refreshDiagnostics(ctx)
+		return
+	}
+	for _, currentFile := range currentFiles.All() {
+		scheduleDiagnostics(ctx, currentFile.diagnosticsParams(), 0)
+	}
+}
+
+func diagnosticSeverity(severity string) protocol.DiagnosticSeverity {
+	switch severity {
+	case "warning":
+		return protocol.DiagnosticSeverityWarning
+	case "information":
+		return protocol.DiagnosticSeverityInformation
+	case "hint":
+		return protocol.DiagnosticSeverityHint
+	}
+	return protocol.DiagnosticSeverityError
+}
@@ -5,35 +5,37 @@ import (
 	"log/slog"
 	"path-intellisense-lsp/src/glsp"
 	protocol "path-intellisense-lsp/src/protocol_3_16"
+	"strings"
 )
 
 type CurrentFile struct {
-	Text       string
 	Version    int32
 	LanguageID string
 	Path       string
+
+	content rope // Text edited by incremental changes, shared between snapshots
+}
+
+// Whole text of the document, built from the rope on each call
+func (s CurrentFile) Text() string {
+	return s.content.String()
 }
 
 // Print CurrentFile for debugging
 func (s CurrentFile) Println() {
-	slog.Info("\n" + s.Path + "\n-----\n" + s.Text + "\n-----\n")
+	slog.Info("\n" + s.Path + "\n-----\n" + s.Text() + "\n-----\n")
 }
 
-var currentFiles = map[string]*CurrentFile{}
-
 func TextDocumentDidOpen(ctx *glsp.Context, params *protocol.DidOpenTextDocumentParams) error {
 	slog.Debug(fmt.Sprintf("Caching openned file: %s", params.TextDocument.URI))
-	currentFiles[params.TextDocument.URI] = &CurrentFile{
-		Text:       params.TextDocument.Text,
+	currentFile := CurrentFile{
 		Version:    params.TextDocument.Version,
 		LanguageID: params.TextDocument.LanguageID,
 		Path:       params.TextDocument.URI,
+		content:    newRope(params.TextDocument.Text),
 	}
-	textDocumentPublishDiagnostics(ctx, &textDocumentPublishDiagnosticsParams{
-		URI:     params.TextDocument.URI,
-		Version: params.TextDocument.Version,
-		Text:    params.TextDocument.Text,
-	})
+	currentFiles.Open(currentFile)
+	scheduleDiagnostics(ctx, currentFile.diagnosticsParams(), 0)
 	return nil
 }
 
@@ -42,79 +44,85 @@ func TextDocumentDidSave(ctx *glsp.Context, params *protocol.DidSaveTextDocument
 	if params.Text == nil {
 		return nil
 	}
-	currentFiles[params.TextDocument.URI].Text = *params.Text
-	textDocumentPublishDiagnostics(ctx, &textDocumentPublishDiagnosticsParams{
-		URI:     params.TextDocument.URI,
-		Version: 0,
-		Text:    *params.Text,
+	currentFile, err := currentFiles.Update(params.TextDocument.URI, func(currentFile *CurrentFile) error {
+		currentFile.content = newRope(*params.Text)
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+	// Saved text replaces the content without a new version, cached lines may not match it
+	forgetDiagnostics(params.TextDocument.URI)
+	reindexDocument(params.TextDocument.URI)
+	scheduleDiagnostics(ctx, currentFile.diagnosticsParams(), 0)
 	return nil
 }
 
 func TextDocumentDidClose(ctx *glsp.Context, params *protocol.DidCloseTextDocumentParams) error {
 	slog.Debug(fmt.Sprintf("Deleting file cache: %s", params.TextDocument.URI))
-	delete(currentFiles, params.TextDocument.URI)
+	currentFiles.Close(params.TextDocument.URI)
+	cancelDiagnostics(params.TextDocument.URI)
+	forgetDiagnostics(params.TextDocument.URI)
+	reindexDocument(params.TextDocument.URI)
 	return nil
 }
 
 func TextDocumentDidChange(ctx *glsp.Context, params *protocol.DidChangeTextDocumentParams) error {
 	slog.Debug(fmt.Sprintf("Updating file cache: %s", params.TextDocument.URI))
-	currentFile := currentFiles[params.TextDocument.URI]
-	lines := textLines(currentFile.Text)
-
-	deleteLineIds := map[uint32]bool{}
-	concatLineIds := map[uint32]bool{}
+	var previousVersion int32
+	var edits []lineEdit
+	currentFile, err := currentFiles.Update(params.TextDocument.URI, func(currentFile *CurrentFile) error {
+		content, contentEdits, err := applyContentChanges(currentFile.content, params.ContentChanges)
+		if err != nil {
+			return err
+		}
+		previousVersion, edits = currentFile.Version, contentEdits
+		currentFile.content = content
+		currentFile.Version = params.TextDocument.Version
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	// Stop scanning the previous version before recording the edit
+	cancelDiagnostics(currentFile.Path)
+	shiftDiagnosticLines(currentFile.Path, previousVersion, currentFile.Version, edits)
+	scheduleDiagnostics(ctx, currentFile.diagnosticsParams(), diagnosticsDelay())
+	return nil
+}
 
-	for _, contentChange := range params.ContentChanges {
+// Apply content changes in order, each range refers to the text left by the previous change.
+// Also returns the lines each change replaced, for rescanning only those.
+func applyContentChanges(content rope, contentChanges []any) (rope, []lineEdit, error) {
+	edits := []lineEdit{}
+	for _, contentChange := range contentChanges {
 		switch v := contentChange.(type) {
 
 		case protocol.TextDocumentContentChangeEventWhole:
-			currentFile.Text = v.Text
+			edits = append(edits, lineEdit{Start: 0, End: content.LineCount() - 1, Lines: strings.Count(v.Text, "\n") + 1})
+			content = newRope(v.Text)
 
 		case protocol.TextDocumentContentChangeEvent:
-			// Adding to text
-			if v.Range.Start == v.Range.End {
-				tmp := lines[v.Range.End.Line]
-				lines[v.Range.End.Line] = tmp[:v.Range.End.Character] + v.Text + tmp[v.Range.End.Character:]
-
-				// Removing from same line
-			} else if v.Range.Start.Line == v.Range.End.Line {
-				tmp := lines[v.Range.End.Line]
-				lines[v.Range.End.Line] = tmp[:v.Range.Start.Character] + tmp[v.Range.End.Character:]
-
-				// Removing from multiple lines
-			} else {
-				lines[v.Range.Start.Line] = lines[v.Range.Start.Line][:v.Range.Start.Character]
-				lines[v.Range.End.Line] = lines[v.Range.End.Line][v.Range.End.Character:]
-				concatLineIds[v.Range.Start.Line] = true
-				for i := v.Range.Start.Line + 1; i < v.Range.End.Line; i++ {
-					deleteLineIds[i] = true
-				}
+			if v.Range == nil {
+				return rope{}, nil, fmt.Errorf("content change without range")
+			}
+			start, err := content.Offset(v.Range.Start.Line, v.Range.Start.Character)
+			if err != nil {
+				return rope{}, nil, fmt.Errorf("content change start: %w", err)
 			}
+			end, err := content.Offset(v.Range.End.Line, v.Range.End.Character)
+			if err != nil {
+				return rope{}, nil, fmt.Errorf("content change end: %w", err)
+			}
+			if end < start {
+				return rope{}, nil, fmt.Errorf("content change range %d:%d-%d:%d is reversed", v.Range.Start.Line, v.Range.Start.Character, v.Range.End.Line, v.Range.End.Character)
+			}
+			edits = append(edits, lineEdit{Start: int(v.Range.Start.Line), End: int(v.Range.End.Line), Lines: strings.Count(v.Text, "\n") + 1})
+			content = content.Replace(start, end, v.Text)
 
 		default:
-			slog.Error("Unkown contentChange type")
+			return rope{}, nil, fmt.Errorf("unknown content change type %T", contentChange)
 		}
 	}
-
-	text := ""
-	for i, line := range lines {
-		if !deleteLineIds[uint32(i)] {
-			// Explicitly concat or is last line
-			if concatLineIds[uint32(i)] || i == len(lines)-1 {
-				text += line
-			} else {
-				text += line + "\n"
-			}
-		}
-	}
-
-	currentFiles[params.TextDocument.URI].Text = text
-	currentFiles[params.TextDocument.URI].Version = params.TextDocument.Version
-	textDocumentPublishDiagnostics(ctx, &textDocumentPublishDiagnosticsParams{
-		URI:     params.TextDocument.URI,
-		Version: params.TextDocument.Version,
-		Text:    text,
-	})
-	return nil
+	return content, edits, nil
 }
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var tsconfigNames = []string{"tsconfig.json", "jsconfig.json"}
+
+// Resolved compiler options relevant to path aliases
+type tsconfig struct {
+	BaseURL   string              // Absolute baseUrl, empty if unset
+	Paths     map[string][]string // "paths" patterns to substitutions
+	PathsBase string              // Absolute directory "paths" substitutions are relative to
+}
+
+type tsconfigFile struct {
+	Extends         any `json:"extends"` // string | []string
+	CompilerOptions struct {
+		BaseURL *string              `json:"baseUrl"`
+		Paths   *map[string][]string `json:"paths"`
+	} `json:"compilerOptions"`
+}
+
+type tsconfigCacheEntry struct {
+	ModTimes map[string]time.Time // Modification times of the config and every config it extends
+	Config   *tsconfig
+}
+
+var (
+	tsconfigCache     = map[string]tsconfigCacheEntry{}
+	tsconfigCacheLock sync.Mutex
+)
+
+// Resolve path through the "paths" aliases of the nearest tsconfig/jsconfig.
+// Returns false if no alias pattern matches path.
+func aliasPathSuggestions(path string, fileUri string, joinPath string) ([]string, bool) {
+	config := nearestTsconfig(fileUri)
+	if config == nil {
+		return []string{}, false
+	}
+
+	for _, pattern := range sortedAliasPatterns(config.Paths) {
+		wildcard, ok := matchAliasPattern(pattern, path)
+		if !ok {
+			continue
+		}
+		for _, substitution := range config.Paths[pattern] {
+			absolutePath := filepath.Join(config.PathsBase, strings.Replace(substitution, "*", wildcard, 1))
+			suggestedAbsolutePaths := absolutePathSuggestions(absolutePath, joinPath)
+			if len(suggestedAbsolutePaths) > 0 {
+				return suggestedAbsolutePaths, true
+			}
+		}
+		return []string{}, true
+	}
+	return []string{}, false
+}
+
+// Absolute path of the first substitution of the matching alias pattern, and the directory substitutions are relative to
+func aliasIntendedPath(path string, fileUri string) (string, string, bool) {
+	config := nearestTsconfig(fileUri)
+	if config == nil {
+		return "", "", false
+	}
+	for _, pattern := range sortedAliasPatterns(config.Paths) {
+		wildcard, ok := matchAliasPattern(pattern, path)
+		if ok && len(config.Paths[pattern]) > 0 {
+			return filepath.Join(config.PathsBase, strings.Replace(config.Paths[pattern][0], "*", wildcard, 1)), config.PathsBase, true
+		}
+	}
+	return "", "", false
+}
+
+// Check whether path matches a "paths" alias pattern of the nearest tsconfig/jsconfig
+func isAliasPath(path string, fileUri string) bool {
+	config := nearestTsconfig(fileUri)
+	if config == nil {
+		return false
+	}
+	for pattern := range config.Paths {
+		if _, ok := matchAliasPattern(pattern, path); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolve bare path against the baseUrl of the nearest tsconfig/jsconfig
+func baseURLPathSuggestions(path string, fileUri string, joinPath string) []string {
+	config := nearestTsconfig(fileUri)
+	if config == nil || config.BaseURL == "" {
+		return []string{}
+	}
+	return absolutePathSuggestions(filepath.Join(config.BaseURL, path), joinPath)
+}
+
+// Match "prefix*suffix" or exact patterns, returning the text captured by "*"
+func matchAliasPattern(pattern string, path string) (string, bool) {
+	prefix, suffix, hasWildcard := strings.Cut(pattern, "*")
+	if !hasWildcard {
+		return "", pattern == path || pattern+"/" == path
+	}
+	if len(path) < len(prefix)+len(suffix) || !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, suffix) {
+		return "", false
+	}
+	return path[len(prefix) : len(path)-len(suffix)], true
+}
+
+// Longest prefix first, matching TypeScript's pattern selection
+func sortedAliasPatterns(paths map[string][]string) []string {
+	patterns := make([]string, 0, len(paths))
+	for pattern := range paths {
+		patterns = append(patterns, pattern)
+	}
+	slices.SortFunc(patterns, func(a, b string) int {
+		prefixA, _, _ := strings.Cut(a, "*")
+		prefixB, _, _ := strings.Cut(b, "*")
+		if len(prefixA) != len(prefixB) {
+			return len(prefixB) - len(prefixA)
+		}
+		return strings.Compare(a, b)
+	})
+	return patterns
+}
+
+// Walk up from the file's directory to find the closest tsconfig.json or jsconfig.json
+func nearestTsconfig(fileUri string) *tsconfig {
+	dir, _ := pathBaseDir(pathBaseFile, fileUri)
+	for {
+		for _, name := range tsconfigNames {
+			configPath := filepath.Join(dir, name)
+			if _, err := os.Stat(configPath); err == nil {
+				return loadTsconfig(configPath)
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return nil
+		}
+		dir = parent
+	}
+}
+
+// Load and cache config, invalidated by modification times of the configs in its "extends" chain
+func loadTsconfig(configPath string) *tsconfig {
+	if _, err := os.Stat(configPath); err != nil {
+		return nil
+	}
+
+	tsconfigCacheLock.Lock()
+	entry, ok := tsconfigCache[configPath]
+	tsconfigCacheLock.Unlock()
+	if ok && isTsconfigCacheFresh(entry) {
+		return entry.Config
+	}
+
+	modTimes := map[string]time.Time{}
+	config, err := parseTsconfig(configPath, map[string]bool{}, modTimes)
+	if err != nil {
+		slog.Warn(fmt.Sprintf("Failed to parse %s: %s", configPath, err))
+		return nil
+	}
+
+	tsconfigCacheLock.Lock()
+	defer tsconfigCacheLock.Unlock()
+	tsconfigCache[configPath] = tsconfigCacheEntry{ModTimes: modTimes, Config: config}
+	return config
+}
+
+// Check whether none of the configs an entry was parsed from changed
+func isTsconfigCacheFresh(entry tsconfigCacheEntry) bool {
+	for path, modTime := range entry.ModTimes {
+		fileInfo, err := os.Stat(path)
+		if err != nil || !fileInfo.ModTime().Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse config and merge its "extends" chain, later configs override earlier ones.
+// visited holds the configs extending this one, modTimes collects the modification times of every config read.
+func parseTsconfig(configPath string, visited map[string]bool, modTimes map[string]time.Time) (*tsconfig, error) {
+	if visited[configPath] {
+		return nil, fmt.Errorf("circular extends: %s", configPath)
+	}
+	// Configs extended by several others, like a shared base, are not circular
+	visited[configPath] = true
+	defer delete(visited, configPath)
+
+	fileInfo, err := os.Stat(configPath)
+	if err != nil {
+		return nil, err
+	}
+	modTimes[configPath] = fileInfo.ModTime()
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+	var file tsconfigFile
+	if err := json.Unmarshal(stripJSONC(data), &file); err != nil {
+		return nil, err
+	}
+
+	config := &tsconfig{}
+	configDir := filepath.Dir(configPath)
+
+	extends := []string{}
+	switch v := file.Extends.(type) {
+	case string:
+		extends = append(extends, v)
+	case []any:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				extends = append(extends, s)
+			}
+		}
+	}
+	for _, extend := range extends {
+		parentPath, ok := resolveTsconfigExtends(extend, configDir)
+		if !ok {
+			slog.Warn(fmt.Sprintf("Cannot resolve extends %q in %s", extend, configPath))
+			continue
+		}
+		parent, err := parseTsconfig(parentPath, visited, modTimes)
+		if err != nil {
+			return nil, err
+		}
+		if parent.BaseURL != "" {
+			config.BaseURL = parent.BaseURL
+		}
+		if parent.Paths != nil {
+			config.Paths = parent.Paths
+			config.PathsBase = parent.PathsBase
+		}
+	}
+
+	if file.CompilerOptions.BaseURL != nil {
+		config.BaseURL = filepath.Join(configDir, *file.CompilerOptions.BaseURL)
+	}
+	if file.CompilerOptions.Paths != nil {
+		config.Paths = *file.CompilerOptions.Paths
+		config.PathsBase = configDir
+	}
+	// Paths are relative to baseUrl when set
+	if config.BaseURL != "" && config.Paths != nil {
+		config.PathsBase = config.BaseURL
+	}
+	return config, nil
+}
+
+// Resolve "extends" as a relative file or a package inside node_modules
+func resolveTsconfigExtends(extend string, configDir string) (string, bool) {
+	candidates := []string{}
+	if strings.HasPrefix(extend, ".") || filepath.IsAbs(extend) {
+		base := extend
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(configDir, extend)
+		}
+		candidates = append(candidates, base, base+".json")
+	} else {
+		for dir := configDir; ; dir = filepath.Dir(dir) {
+			base := filepath.Join(dir, "node_modules", extend)
+			candidates = append(candidates, base, base+".json", filepath.Join(base, "tsconfig.json"))
+			if filepath.Dir(dir) == dir {
+				break
+			}
+		}
+	}
+
+	for _, candidate := range candidates {
+		fileInfo, err := os.Stat(candidate)
+		if err == nil && !fileInfo.IsDir() {
+			return candidate, true
+		}
+	}
+	return "", false
+}
+
+// Strip comments and trailing commas from JSONC so it can be parsed as JSON
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out = append(out, c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out = append(out, data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+
+		switch {
+		case c == '"':
+			inString = true
+			out = append(out, c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			out = append(out, '\n')
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			i += 2
+			for i+1 < len(data) && !(data[i] == '*' && data[i+1] == '/') {
+				i++
+			}
+			i++
+		case c == '}' || c == ']':
+			// Drop trailing comma before closing bracket
+			j := len(out) - 1
+			for j >= 0 && strings.ContainsRune(" \t\r\n", rune(out[j])) {
+				j--
+			}
+			if j >= 0 && out[j] == ',' {
+				out = append(out[:j], out[j+1:]...)
+			}
+			out = append(out, c)
+		default:
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// Write files relative to dir, creating their directories
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// Configs extended through several others, like a shared base, are merged like any other
+func TestParseTsconfigDiamondExtends(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"base.json":     `{"compilerOptions": {"baseUrl": "src", "paths": {"@/*": ["./*"]}}}`,
+		"a.json":        `{"extends": "./base.json"}`,
+		"b.json":        `{"extends": "./base"}`,
+		"tsconfig.json": `{"extends": ["./a.json", "./b.json"]}`,
+	})
+
+	config := loadTsconfig(filepath.Join(dir, "tsconfig.json"))
+	if config == nil {
+		t.Fatal("diamond extends failed to load")
+	}
+	if config.BaseURL != filepath.Join(dir, "src") || config.PathsBase != filepath.Join(dir, "src") || len(config.Paths["@/*"]) != 1 {
+		t.Fatalf("unexpected config %+v", config)
+	}
+}
+
+func TestParseTsconfigCircularExtends(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.json":        `{"extends": "./b.json"}`,
+		"b.json":        `{"extends": "./a.json"}`,
+		"tsconfig.json": `{"extends": "./a.json"}`,
+	})
+
+	if _, err := parseTsconfig(filepath.Join(dir, "tsconfig.json"), map[string]bool{}, map[string]time.Time{}); err == nil {
+		t.Fatal("circular extends parsed without error")
+	}
+}
+
+// Editing an extended config invalidates configs extending it
+func TestLoadTsconfigExtendedConfigChanged(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"tsconfig.base.json":       `{"compilerOptions": {"paths": {"@old/*": ["./*"]}}}`,
+		"packages/a/tsconfig.json": `{"extends": "../../tsconfig.base.json"}`,
+	})
+	configPath := filepath.Join(dir, "packages/a/tsconfig.json")
+	if config := loadTsconfig(configPath); config == nil || config.Paths["@old/*"] == nil {
+		t.Fatalf("unexpected config %+v", config)
+	}
+
+	writeTestFiles(t, dir, map[string]string{
+		"tsconfig.base.json": `{"compilerOptions": {"paths": {"@new/*": ["./*"]}}}`,
+	})
+	// Modification times may not advance between quick writes
+	basePath := filepath.Join(dir, "tsconfig.base.json")
+	if err := os.Chtimes(basePath, time.Time{}, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if config := loadTsconfig(configPath); config == nil || config.Paths["@new/*"] == nil {
+		t.Fatalf("stale config %+v", config)
+	}
+}
@@ -4,21 +4,46 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
 	"os/user"
 	"path/filepath"
 	"regexp"
-)
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
 
-const (
-	triggerCharacter   = "(\"|'|`| |\n)" // """ or "'" or "`" or " " or "\n"
-	optionalPathPrefix = "([.]{1,2}|~)?" // "." or ".." or "~"
-	illegalCharacters  = "\\/:?\"<>|\r\n &"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
 )
 
-var regexCache = map[string]*regexp.Regexp{}
+const triggerCharacter = "(\"|'|`| |\n)" // """ or "'" or "`" or " " or "\n"
+
+// Path segment excluding the configured illegal characters
+func pathSegment() string {
+	escaped := ""
+	for _, char := range currentSettings().IllegalCharacters {
+		if char <= unicode.MaxASCII && (unicode.IsPunct(char) || unicode.IsSymbol(char)) {
+			escaped += "\\"
+		}
+		escaped += string(char)
+	}
+	return "[^" + escaped + "]+"
+}
+
+// "." or ".." or "~" or bare segment like "src"
+func optionalPathPrefix() string {
+	return "([.]{1,2}|~|" + pathSegment() + ")?"
+}
+
+var (
+	regexCache     = map[string]*regexp.Regexp{}
+	regexCacheLock sync.Mutex
+)
 
 // Lazy compile regex and cache for reuse
 func mustCompileLazyRegex(filter string) *regexp.Regexp {
+	regexCacheLock.Lock()
+	defer regexCacheLock.Unlock()
 	if regexCache[filter] != nil {
 		return regexCache[filter]
 	}
@@ -29,7 +54,7 @@ func mustCompileLazyRegex(filter string) *regexp.Regexp {
 
 // Get last match of valid file path
 func extractPathsRegex(text string) ([]string, error) {
-	re := mustCompileLazyRegex(triggerCharacter + optionalPathPrefix + fmt.Sprintf("(/[^%s]+)*", illegalCharacters) + "/")
+	re := mustCompileLazyRegex(triggerCharacter + optionalPathPrefix() + "(/" + pathSegment() + ")*/")
 	matches := re.FindAllString("\n"+text, -1)
 	if len(matches) == 0 {
 		return []string{}, errors.New("no path matching strings found")
@@ -47,18 +72,125 @@ func textLines(text string) []string {
 }
 
 func matchPath(path string, fileUri string, joinPath string) []string {
+	return matchPathInLanguage(path, fileUri, joinPath, languageIDOf(fileUri))
+}
+
+// Match path for a file of the given language, which decides the probed extensions
+func matchPathInLanguage(path string, fileUri string, joinPath string, languageID string) []string {
+	suggestedAbsolutePaths := resolvePathSuggestions(path, fileUri, joinPath)
+	if len(suggestedAbsolutePaths) > 0 || joinPath != "" {
+		return suggestedAbsolutePaths
+	}
+
+	// Probe extension-less paths like "./utils/format" for "./utils/format.ts"
+	for _, extension := range languageRulesFor(languageID).Extensions {
+		if suggestedAbsolutePaths := resolvePathSuggestions(path+extension, fileUri, joinPath); len(suggestedAbsolutePaths) > 0 {
+			return suggestedAbsolutePaths
+		}
+	}
+	return []string{}
+}
+
+func resolvePathSuggestions(path string, fileUri string, joinPath string) []string {
+	if path == "" {
+		return []string{}
+	}
+	// Aliases like "@/components" or "~lib/utils" take precedence over other prefixes
+	if suggestedAbsolutePaths, ok := aliasPathSuggestions(path, fileUri, joinPath); ok {
+		return suggestedAbsolutePaths
+	}
+
 	switch string(path[0]) {
 	case "/":
-		return absolutePathSuggestions(path, joinPath)
+		return precedencePathSuggestions(currentSettings().RootPathPrecedence, path, fileUri, joinPath)
 	case "~":
 		return homePathSuggestions(path, joinPath)
 	case ".":
 		return relativePathSuggestions(path, fileUri, joinPath)
 	}
-	return []string{}
+	if suggestedAbsolutePaths := baseURLPathSuggestions(path, fileUri, joinPath); len(suggestedAbsolutePaths) > 0 {
+		return suggestedAbsolutePaths
+	}
+	if suggestedAbsolutePaths := nodeModulesPathSuggestions(path, fileUri, joinPath); len(suggestedAbsolutePaths) > 0 {
+		return suggestedAbsolutePaths
+	}
+	return precedencePathSuggestions(currentSettings().BarePathPrecedence, path, fileUri, joinPath)
+}
+
+// Absolute path a missing path was expected at, under the base where most of it exists
+func intendedAbsolutePath(path string, fileUri string) (string, bool) {
+	absolutePath, _, _, ok := intendedPathResolution(path, fileUri)
+	return absolutePath, ok
+}
+
+// Absolute path a missing path was expected at, the base it was resolved against and that base's directory
+func intendedPathResolution(path string, fileUri string) (string, pathBase, string, bool) {
+	if path == "" {
+		return "", "", "", false
+	}
+	if absolutePath, baseDir, ok := aliasIntendedPath(path, fileUri); ok {
+		return absolutePath, pathBaseAlias, baseDir, true
+	}
+
+	precedence := currentSettings().BarePathPrecedence
+	switch string(path[0]) {
+	case "/":
+		precedence = currentSettings().RootPathPrecedence
+	case "~":
+		currentUser, err := user.Current()
+		if err != nil {
+			return "", "", "", false
+		}
+		return filepath.Join(currentUser.HomeDir, path[1:]), pathBaseHome, currentUser.HomeDir, true
+	case ".":
+		precedence = []pathBase{pathBaseFile}
+	}
+	// The base the existing part of the path resolves against, e.g. "<workspace>/public" for
+	// "/public/missing.svg", ties going to the earlier base
+	intendedPath, intendedBase, intendedBaseDir, depth := "", pathBase(""), "", -1
+	for _, base := range precedence {
+		baseDir, ok := pathBaseDir(base, fileUri)
+		if !ok {
+			continue
+		}
+		absolutePath := filepath.Join(baseDir, path)
+		if existingDepth := existingPathDepth(baseDir, absolutePath); existingDepth > depth {
+			intendedPath, intendedBase, intendedBaseDir, depth = absolutePath, base, baseDir, existingDepth
+		}
+	}
+	return intendedPath, intendedBase, intendedBaseDir, depth >= 0
+}
+
+// Number of segments below baseDir of the deepest existing ancestor of absolutePath
+func existingPathDepth(baseDir string, absolutePath string) int {
+	for current := absolutePath; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil {
+			relativePath, err := filepath.Rel(baseDir, current)
+			if err != nil || relativePath == "." || !filepath.IsLocal(relativePath) {
+				return 0
+			}
+			return len(strings.Split(relativePath, string(filepath.Separator)))
+		}
+		if filepath.Dir(current) == current {
+			return 0
+		}
+	}
+}
+
+// Check whether path has no "/", "~" or "." prefix, e.g. "src/assets/logo.png"
+func isBarePath(path string) bool {
+	return path != "" && !strings.ContainsAny(path[:1], "/~.")
 }
 
 func absolutePathSuggestions(absolutePath string, joinPath string) []string {
+	if joinPath == "" {
+		watchResolvedDir(filepath.Dir(absolutePath))
+	} else {
+		watchResolvedDir(absolutePath)
+	}
+	if suggestedAbsolutePaths, ok := indexedPathSuggestions(absolutePath, joinPath); ok {
+		return suggestedAbsolutePaths
+	}
 	searchPath := filepath.Join(absolutePath, joinPath)
 	suggestedAbsolutePaths, err := filepath.Glob(searchPath)
 	if err != nil {
@@ -77,19 +209,47 @@ func homePathSuggestions(path string, joinPath string) []string {
 }
 
 func relativePathSuggestions(path string, fileUri string, joinPath string) []string {
-	currentAbsoluteDirPath, _ := filepath.Split(fileUri[7:])
+	currentAbsoluteDirPath, _ := pathBaseDir(pathBaseFile, fileUri)
 	absolutePath := filepath.Join(currentAbsoluteDirPath, path)
 	return absolutePathSuggestions(absolutePath, joinPath)
 }
 
+// Resolve path against each base in order, returning the first non-empty suggestions
+func precedencePathSuggestions(precedence []pathBase, path string, fileUri string, joinPath string) []string {
+	for _, base := range precedence {
+		baseDir, ok := pathBaseDir(base, fileUri)
+		if !ok {
+			continue
+		}
+		suggestedAbsolutePaths := absolutePathSuggestions(filepath.Join(baseDir, path), joinPath)
+		if len(suggestedAbsolutePaths) > 0 {
+			return suggestedAbsolutePaths
+		}
+	}
+	return []string{}
+}
+
 type pathMatch struct {
 	Text  string
-	Start int
+	Start int // Byte offsets in the line
 	End   int
+
+	StartCharacter uint32 // Offsets in the negotiated position encoding
+	EndCharacter   uint32
+}
+
+func newPathMatch(line string, start int, end int) pathMatch {
+	return pathMatch{
+		Text:           line[start:end],
+		Start:          start,
+		End:            end,
+		StartCharacter: characterOffset(line, start),
+		EndCharacter:   characterOffset(line, end),
+	}
 }
 
 func findPathMatches(line string) []pathMatch {
-	re := mustCompileLazyRegex(triggerCharacter + optionalPathPrefix + fmt.Sprintf("(/[^%s]+)+", illegalCharacters))
+	re := mustCompileLazyRegex(triggerCharacter + optionalPathPrefix() + "(/" + pathSegment() + ")+")
 	matches := re.FindAllStringIndex("\n"+line, -1)
 
 	results := make([]pathMatch, 0, len(matches))
@@ -101,11 +261,43 @@ func findPathMatches(line string) []pathMatch {
 			slog.Error(fmt.Sprintf("Failed to extract path from line:\n%s\nstart(%d), end(%d), len(%d)", line, start, end, len(line)))
 			continue
 		}
-		results = append(results, pathMatch{
-			Start: start,
-			End:   end,
-			Text:  line[start:end],
-		})
+		results = append(results, newPathMatch(line, start, end))
 	}
 	return results
 }
+
+// Range of the match on a given line
+func (m pathMatch) Range(line int) protocol.Range {
+	return protocol.Range{
+		Start: protocol.Position{Line: uint32(line), Character: m.StartCharacter},
+		End:   protocol.Position{Line: uint32(line), Character: m.EndCharacter},
+	}
+}
+
+// Path matches of a line, including bare import specifiers for node languages
+func linePathMatches(line string, languageID string) []pathMatch {
+	matches := findPathMatches(line)
+	if slices.Contains(nodeLanguageIDs, languageID) {
+		matches = append(matches, findImportSpecifierMatches(line)...)
+	}
+	return matches
+}
+
+// Find the path under the cursor of an open file
+func pathMatchAt(fileUri string, position protocol.Position) (pathMatch, bool) {
+	currentFile, ok := currentFiles.Get(fileUri)
+	if !ok {
+		return pathMatch{}, false
+	}
+	line, err := currentFile.Line(position.Line)
+	if err != nil {
+		return pathMatch{}, false
+	}
+	offset := byteOffset(line, position.Character)
+	for _, match := range linePathMatches(line, currentFile.LanguageID) {
+		if match.Start <= offset && offset <= match.End {
+			return match, true
+		}
+	}
+	return pathMatch{}, false
+}
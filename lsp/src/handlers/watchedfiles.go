@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+const fileWatchersRegistrationID = "path-intellisense-lsp/watchedFiles"
+
+// Whether the client agreed to report every file change, keeping the workspace index current
+var fileWatchersRegistered atomic.Bool
+
+// File changes waiting for the index worker
+var (
+	pendingFileChanges     []protocol.FileEvent
+	pendingFileChangesLock sync.Mutex
+)
+
+// Ask the client to report every file change in the workspace.
+func registerFileWatchers(ctx *glsp.Context) {
+	err := ctx.Call(protocol.ServerClientRegisterCapability, &protocol.RegistrationParams{
+		Registrations: []protocol.Registration{{
+			ID:     fileWatchersRegistrationID,
+			Method: string(protocol.MethodWorkspaceDidChangeWatchedFiles),
+			RegisterOptions: protocol.DidChangeWatchedFilesRegistrationOptions{
+				Watchers: []protocol.FileSystemWatcher{{GlobPattern: "**/*"}},
+			},
+		}},
+	}, nil)
+	if err != nil {
+		return
+	}
+	fileWatchersRegistered.Store(true)
+	slog.Debug("Registered file watchers")
+}
+
+func WorkspaceDidChangeWatchedFiles(ctx *glsp.Context, params *protocol.DidChangeWatchedFilesParams) error {
+	slog.Debug(fmt.Sprintf("WorkspaceDidChangeWatchedFiles: %d changes", len(params.Changes)))
+	applyFileChanges(ctx, params.Changes)
+	return nil
+}
+
+// Update the index and diagnostics of open documents after files changed on disk.
+// Documents are scanned on the index worker, so bursts of changes don't hold up other messages.
+func applyFileChanges(ctx *glsp.Context, changes []protocol.FileEvent) {
+	pendingFileChangesLock.Lock()
+	pendingFileChanges = append(pendingFileChanges, changes...)
+	pendingFileChangesLock.Unlock()
+
+	// Batches arriving while one is pending are applied with it
+	queueIndexUpdate("fileChanges", func() {
+		pendingFileChangesLock.Lock()
+		changes := pendingFileChanges
+		pendingFileChanges = nil
+		pendingFileChangesLock.Unlock()
+
+		// Merged batches often repeat paths, e.g. files written several times
+		changedPaths, seen := []string{}, map[string]bool{}
+		for _, change := range changes {
+			if path := uriPath(change.URI); !seen[path] {
+				seen[path] = true
+				changedPaths = append(changedPaths, path)
+			}
+		}
+		pullDiagnostics := clientSupportsPullDiagnostics()
+		// Find affected documents before the index forgets what their paths resolved to
+		affected := []string{}
+		if !pullDiagnostics {
+			affected = affectedDocuments(changedPaths)
+		}
+		updateIndexedFiles(changes)
+
+		// Publish once the index reflects the changes, unless the server is shutting down
+		invalidateResolutions()
+		if indexWorkerStopped.Load() {
+			return
+		}
+		if pullDiagnostics {
+			go refreshDiagnostics(ctx)
+			return
+		}
+		// Documents may have changed while the update was queued, their current version is checked
+		for _, uri := range affected {
+			if currentFile, ok := currentFiles.Get(uri); ok {
+				scheduleDiagnostics(ctx, currentFile.diagnosticsParams(), 0)
+			}
+		}
+	})
+}
+
+// URIs of open documents with a path string resolving to, or expected at, one of the changed paths
+func affectedDocuments(changedPaths []string) []string {
+	configChanged := slices.ContainsFunc(changedPaths, isResolutionConfigChange)
+
+	affected := []string{}
+	for _, currentFile := range currentFiles.All() {
+		if configChanged || documentRefersTo(*currentFile.diagnosticsParams(), changedPaths) {
+			affected = append(affected, currentFile.Path)
+		}
+	}
+	return affected
+}
+
+// Check whether a change of path can alter how paths resolve in every file, ignoring configs
+// in directories the index excludes, like the package.json files npm install writes to node_modules
+func isResolutionConfigChange(path string) bool {
+	name := filepath.Base(path)
+	return (name == ".gitignore" || slices.Contains(resolutionConfigNames, name)) && !isIndexExcluded(path)
+}
+
+func documentRefersTo(document textDocumentPublishDiagnosticsParams, changedPaths []string) bool {
+	for _, line := range document.content.Lines() {
+		for _, match := range findPathMatches(line) {
+			absolutePaths := matchPathInLanguage(match.Text, document.URI, "", document.LanguageID)
+			if intendedPath, ok := intendedAbsolutePath(match.Text, document.URI); ok {
+				absolutePaths = append(absolutePaths, intendedPath)
+			}
+			for _, absolutePath := range absolutePaths {
+				for _, changedPath := range changedPaths {
+					if pathAffectedBy(absolutePath, changedPath) {
+						return true
+					}
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Check whether a change of changedPath can alter whether absolutePath exists
+func pathAffectedBy(absolutePath string, changedPath string) bool {
+	if isWithinDir(absolutePath, changedPath) || isWithinDir(changedPath, absolutePath) {
+		return true
+	}
+	// Extension-less paths resolve to probed files like "format.ts"
+	return filepath.Dir(absolutePath) == filepath.Dir(changedPath) && strings.HasPrefix(filepath.Base(changedPath), filepath.Base(absolutePath)+".")
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Base directory a path can be resolved against
+type pathBase string
+
+const (
+	pathBaseFile       pathBase = "file"       // Directory of the open file
+	pathBaseWorkspace  pathBase = "workspace"  // Workspace folder owning the open file
+	pathBaseFilesystem pathBase = "filesystem" // Filesystem root
+	pathBaseAlias      pathBase = "alias"      // "paths" base of the nearest tsconfig/jsconfig, not configurable
+	pathBaseHome       pathBase = "home"       // Home directory of "~" paths, not configurable
+)
+
+var (
+	workspaceFolders     = []string{}
+	workspaceFoldersLock sync.RWMutex
+)
+
+// Capture workspace folders from initialize params.
+// WorkspaceFolders takes precedence over the deprecated RootURI and RootPath.
+func SetWorkspaceFolders(params *protocol.InitializeParams) {
+	folders := []string{}
+	if len(params.WorkspaceFolders) > 0 {
+		for _, folder := range params.WorkspaceFolders {
+			folders = append(folders, uriPath(folder.URI))
+		}
+	} else if params.RootURI != nil {
+		folders = append(folders, uriPath(*params.RootURI))
+	} else if params.RootPath != nil {
+		folders = append(folders, filepath.Clean(*params.RootPath))
+	}
+
+	workspaceFoldersLock.Lock()
+	defer workspaceFoldersLock.Unlock()
+	workspaceFolders = folders
+	slog.Debug(fmt.Sprintf("Workspace folders: %v", workspaceFolders))
+}
+
+func WorkspaceDidChangeWorkspaceFolders(ctx *glsp.Context, params *protocol.DidChangeWorkspaceFoldersParams) error {
+	workspaceFoldersLock.Lock()
+	for _, removed := range params.Event.Removed {
+		workspaceFolders = slices.DeleteFunc(workspaceFolders, func(folder string) bool {
+			return folder == uriPath(removed.URI)
+		})
+	}
+	for _, added := range params.Event.Added {
+		if !slices.Contains(workspaceFolders, uriPath(added.URI)) {
+			workspaceFolders = append(workspaceFolders, uriPath(added.URI))
+		}
+	}
+	slog.Debug(fmt.Sprintf("Workspace folders changed: %v", workspaceFolders))
+	workspaceFoldersLock.Unlock()
+
+	invalidateResolutions()
+	indexWorkspaceFolders()
+	return nil
+}
+
+// Find the innermost workspace folder containing the file
+func workspaceFolderOf(fileUri string) (string, bool) {
+	workspaceFoldersLock.RLock()
+	defer workspaceFoldersLock.RUnlock()
+
+	filePath := uriPath(fileUri)
+	owner := ""
+	for _, folder := range workspaceFolders {
+		if isWithinDir(filePath, folder) && len(folder) > len(owner) {
+			owner = folder
+		}
+	}
+	return owner, owner != ""
+}
+
+// Resolve the directory a path base refers to for the open file
+func pathBaseDir(base pathBase, fileUri string) (string, bool) {
+	switch base {
+	case pathBaseFile:
+		dir, _ := filepath.Split(uriPath(fileUri))
+		return dir, true
+	case pathBaseWorkspace:
+		return workspaceFolderOf(fileUri)
+	case pathBaseFilesystem:
+		return "/", true
+	}
+	return "", false
+}
+
+// Convert a "file://" URI into an absolute filesystem path
+func uriPath(uri string) string {
+	path := strings.TrimPrefix(uri, "file://")
+	if unescaped, err := url.PathUnescape(path); err == nil {
+		path = unescaped
+	}
+	return filepath.Clean(path)
+}
+
+func isWithinDir(path string, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, "../")
+}
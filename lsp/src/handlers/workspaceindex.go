@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Files whose changes affect how paths resolve in every workspace file
+var resolutionConfigNames = []string{"tsconfig.json", "jsconfig.json", "package.json"}
+
+// Pattern from a ".gitignore" file, relative to the directory holding it
+type ignoreRule struct {
+	Pattern  string
+	DirOnly  bool
+	Anchored bool
+}
+
+// Background index of workspace folders: the file tree used to resolve paths
+// and a reverse index of path references found in files on disk.
+// Only the index worker writes to it, readers take indexLock.
+var (
+	indexedFolders     = map[string]bool{}            // Workspace folders fully indexed
+	indexedChildren    = map[string]map[string]bool{} // Directory -> child name -> is directory
+	indexedExcluded    = map[string]bool{}            // Ignored paths, resolved against the filesystem instead
+	indexedIgnoreRules = map[string][]ignoreRule{}    // Directory -> rules of its ".gitignore"
+	indexedReferences  = map[string][]pathReference{} // File URI -> path references on disk
+	indexedReferrers   = map[string]map[string]bool{} // Absolute path -> URIs of files referring to it
+	indexLock          sync.RWMutex
+)
+
+// Update of the index waiting for the worker
+type indexUpdate struct {
+	Key   string // Later updates with the same key are merged into the pending one
+	Apply func()
+}
+
+var (
+	pendingIndexUpdates     = []indexUpdate{}
+	pendingIndexUpdatesLock sync.Mutex
+	indexUpdatesQueued      = make(chan struct{}, 1)
+	indexWorkerStarted      bool
+	indexWorkerStopped      atomic.Bool // Set on shutdown, long updates check it to stop early
+	indexWorkerDone         = make(chan struct{})
+)
+
+// Queue an update of the index, applied in order by a single worker without blocking the caller.
+// An update is dropped while one with the same key is pending, as that one reads the current state when applied.
+// Updates queued after shutdown are dropped.
+func queueIndexUpdate(key string, update func()) {
+	pendingIndexUpdatesLock.Lock()
+	defer pendingIndexUpdatesLock.Unlock()
+	if indexWorkerStopped.Load() {
+		return
+	}
+	if !indexWorkerStarted {
+		indexWorkerStarted = true
+		go runIndexWorker()
+	}
+	if !slices.ContainsFunc(pendingIndexUpdates, func(pending indexUpdate) bool { return pending.Key == key }) {
+		pendingIndexUpdates = append(pendingIndexUpdates, indexUpdate{Key: key, Apply: update})
+	}
+	select {
+	case indexUpdatesQueued <- struct{}{}:
+	default:
+		// The worker was signalled already and takes every pending update
+	}
+}
+
+// Drop pending updates and wait for the worker to finish the one it is applying, e.g. on shutdown
+func stopIndexWorker() {
+	pendingIndexUpdatesLock.Lock()
+	if indexWorkerStopped.Load() {
+		pendingIndexUpdatesLock.Unlock()
+		return
+	}
+	indexWorkerStopped.Store(true)
+	pendingIndexUpdates = nil
+	close(indexUpdatesQueued)
+	started := indexWorkerStarted
+	pendingIndexUpdatesLock.Unlock()
+
+	if started {
+		<-indexWorkerDone
+	}
+}
+
+// Apply pending updates whenever some are queued, until the worker is stopped
+func runIndexWorker() {
+	defer close(indexWorkerDone)
+	for range indexUpdatesQueued {
+		for {
+			pendingIndexUpdatesLock.Lock()
+			if len(pendingIndexUpdates) == 0 {
+				pendingIndexUpdatesLock.Unlock()
+				break
+			}
+			update := pendingIndexUpdates[0]
+			pendingIndexUpdates = pendingIndexUpdates[1:]
+			pendingIndexUpdatesLock.Unlock()
+			update.Apply()
+		}
+	}
+}
+
+// Index workspace folders not indexed yet and forget removed ones
+func indexWorkspaceFolders() {
+	queueIndexUpdate("folders", func() {
+		workspaceFoldersLock.RLock()
+		folders := slices.Clone(workspaceFolders)
+		workspaceFoldersLock.RUnlock()
+
+		for folder := range indexedFolders {
+			if !slices.Contains(folders, folder) {
+				removeIndexedPath(folder)
+				indexLock.Lock()
+				delete(indexedFolders, folder)
+				indexLock.Unlock()
+			}
+		}
+		for _, folder := range folders {
+			if !indexedFolders[folder] {
+				indexFolder(folder)
+			}
+		}
+	})
+}
+
+// Rebuild a folder's index from disk
+func indexFolder(folder string) {
+	slog.Debug(fmt.Sprintf("Indexing workspace folder: %s", folder))
+	removeIndexedPath(folder)
+	files := indexTree(folder, folder)
+
+	indexLock.Lock()
+	indexedFolders[folder] = true
+	indexLock.Unlock()
+	slog.Debug(fmt.Sprintf("Indexed workspace folder: %s, %d files", folder, files))
+}
+
+// File tree found by walking a directory, merged into the index in one step
+type indexScan struct {
+	Children    map[string]map[string]bool
+	Excluded    map[string]bool
+	IgnoreRules map[string][]ignoreRule
+	Files       []string
+}
+
+// Walk root within folder, skipping ignored paths, then index path references of its files.
+// Returns the number of files indexed.
+func indexTree(root string, folder string) int {
+	scan := indexScan{
+		Children:    map[string]map[string]bool{},
+		Excluded:    map[string]bool{},
+		IgnoreRules: map[string][]ignoreRule{},
+		Files:       []string{},
+	}
+	rulesOf := func(dir string) []ignoreRule {
+		if rules, ok := scan.IgnoreRules[dir]; ok {
+			return rules
+		}
+		return indexedIgnoreRules[dir]
+	}
+
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if indexWorkerStopped.Load() {
+			return filepath.SkipAll
+		}
+		if err != nil {
+			return nil
+		}
+		if path != root {
+			scan.Children[filepath.Dir(path)][entry.Name()] = entry.IsDir()
+			if isIgnoredPath(path, entry.IsDir(), folder, rulesOf) {
+				scan.Excluded[path] = true
+				if entry.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if entry.IsDir() {
+			watchIndexedDir(path)
+			scan.Children[path] = map[string]bool{}
+			scan.IgnoreRules[path] = readIgnoreRules(path)
+			return nil
+		}
+		if fileInfo, err := entry.Info(); err == nil && fileInfo.Mode().IsRegular() && fileInfo.Size() <= maxScannedFileSize {
+			scan.Files = append(scan.Files, path)
+		}
+		return nil
+	})
+
+	// Resolve references once the tree is known, so targets inside it are found
+	commitIndexScan(scan)
+	for _, path := range scan.Files {
+		if indexWorkerStopped.Load() {
+			break
+		}
+		reindexFileReferences(path)
+	}
+	return len(scan.Files)
+}
+
+// Merge the file tree of a scan into the index
+func commitIndexScan(scan indexScan) {
+	indexLock.Lock()
+	defer indexLock.Unlock()
+	for dir, children := range scan.Children {
+		if parent := filepath.Dir(dir); indexedChildren[parent] != nil {
+			indexedChildren[parent][filepath.Base(dir)] = true
+		}
+		indexedChildren[dir] = children
+	}
+	for path := range scan.Excluded {
+		indexedExcluded[path] = true
+	}
+	for dir, rules := range scan.IgnoreRules {
+		indexedIgnoreRules[dir] = rules
+	}
+}
+
+// Replace the references of a file, keeping the reverse index in sync
+func setIndexedReferences(uri string, references []pathReference) {
+	indexLock.Lock()
+	defer indexLock.Unlock()
+	for _, reference := range indexedReferences[uri] {
+		if referrers := indexedReferrers[reference.AbsolutePath]; referrers != nil {
+			delete(referrers, uri)
+			if len(referrers) == 0 {
+				delete(indexedReferrers, reference.AbsolutePath)
+			}
+		}
+	}
+	if references == nil {
+		delete(indexedReferences, uri)
+		return
+	}
+	indexedReferences[uri] = references
+	for _, reference := range references {
+		if reference.AbsolutePath == "" {
+			continue
+		}
+		if indexedReferrers[reference.AbsolutePath] == nil {
+			indexedReferrers[reference.AbsolutePath] = map[string]bool{}
+		}
+		indexedReferrers[reference.AbsolutePath][uri] = true
+	}
+}
+
+// Forget a path and everything below it
+func removeIndexedPath(path string) {
+	uris := []string{}
+	indexLock.Lock()
+	if children := indexedChildren[filepath.Dir(path)]; children != nil {
+		delete(children, filepath.Base(path))
+	}
+	for dir := range indexedChildren {
+		if isWithinDir(dir, path) {
+			delete(indexedChildren, dir)
+			delete(indexedIgnoreRules, dir)
+		}
+	}
+	for excluded := range indexedExcluded {
+		if isWithinDir(excluded, path) {
+			delete(indexedExcluded, excluded)
+		}
+	}
+	for uri := range indexedReferences {
+		if isWithinDir(uriPath(uri), path) {
+			uris = append(uris, uri)
+		}
+	}
+	indexLock.Unlock()
+
+	for _, uri := range uris {
+		setIndexedReferences(uri, nil)
+	}
+}
+
+// Re-read references of a file from disk
+func reindexFileReferences(path string) {
+	uri := "file://" + path
+	text, ok := readFileText(path)
+	if !ok {
+		setIndexedReferences(uri, nil)
+		return
+	}
+	setIndexedReferences(uri, textPathReferences(uri, text, extensionLanguageIDs[filepath.Ext(path)]))
+}
+
+// Resolve indexed references again after files were created or deleted
+func reresolveIndexedReferences() {
+	indexLock.RLock()
+	uris := make([]string, 0, len(indexedReferences))
+	for uri := range indexedReferences {
+		uris = append(uris, uri)
+	}
+	indexLock.RUnlock()
+
+	for _, uri := range uris {
+		if indexWorkerStopped.Load() {
+			return
+		}
+		indexLock.RLock()
+		references := slices.Clone(indexedReferences[uri])
+		indexLock.RUnlock()
+		for i, reference := range references {
+			references[i].AbsolutePath = resolvePathReference(reference.Match.Text, uri, extensionLanguageIDs[filepath.Ext(uri)])
+		}
+		setIndexedReferences(uri, references)
+	}
+}
+
+// Apply changes of files on disk to the index, on the index worker
+func updateIndexedFiles(changes []protocol.FileEvent) {
+	reresolve := false
+	for _, change := range changes {
+		path := uriPath(change.URI)
+		name := filepath.Base(path)
+		if name == ".gitignore" {
+			// Ignore rules changed, rebuild the owning folder
+			if folder, ok := indexedFolderOf(path); ok && !isIndexExcluded(path) {
+				indexFolder(folder)
+				reresolve = true
+			}
+			continue
+		}
+		if isResolutionConfigChange(path) {
+			reresolve = true
+		}
+
+		switch change.Type {
+		case protocol.FileChangeTypeCreated:
+			folder, ok := indexedFolderOf(path)
+			if !ok || !indexCovers(filepath.Dir(path)) {
+				continue
+			}
+			fileInfo, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			removeIndexedPath(path)
+			ignored := isIgnoredPath(path, fileInfo.IsDir(), folder, func(dir string) []ignoreRule { return indexedIgnoreRules[dir] })
+			indexLock.Lock()
+			if children := indexedChildren[filepath.Dir(path)]; children != nil {
+				children[name] = fileInfo.IsDir()
+			}
+			if ignored {
+				indexedExcluded[path] = true
+			}
+			indexLock.Unlock()
+			if !ignored && fileInfo.IsDir() {
+				indexTree(path, folder)
+			} else if !ignored {
+				reindexFileReferences(path)
+			}
+			reresolve = true
+
+		case protocol.FileChangeTypeChanged:
+			if indexCovers(path) {
+				reindexFileReferences(path)
+			}
+
+		case protocol.FileChangeTypeDeleted:
+			removeIndexedPath(path)
+			reresolve = true
+		}
+	}
+	if reresolve {
+		reresolveIndexedReferences()
+	}
+}
+
+// Refresh references of a file whose disk content may differ from the open document
+func reindexDocument(fileUri string) {
+	queueIndexUpdate("document "+fileUri, func() {
+		if path := uriPath(fileUri); indexCovers(path) {
+			reindexFileReferences(path)
+		}
+	})
+}
+
+// Indexed workspace folder containing path
+func indexedFolderOf(path string) (string, bool) {
+	indexLock.RLock()
+	defer indexLock.RUnlock()
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if indexedFolders[dir] {
+			return dir, true
+		}
+		if dir == filepath.Dir(dir) {
+			return "", false
+		}
+	}
+}
+
+// Check whether path is, or is within, a path the index excludes, like node_modules or ".gitignore"d paths
+func isIndexExcluded(path string) bool {
+	indexLock.RLock()
+	defer indexLock.RUnlock()
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if indexedExcluded[dir] || (dir != path && slices.Contains(ignoredDirNames, filepath.Base(dir))) {
+			return true
+		}
+		if indexedFolders[dir] || dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// Check whether the index is authoritative for path
+func indexCovers(path string) bool {
+	indexLock.RLock()
+	defer indexLock.RUnlock()
+	return indexCoversLocked(path)
+}
+
+func indexCoversLocked(path string) bool {
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if indexedExcluded[dir] {
+			return false
+		}
+		if indexedFolders[dir] {
+			return true
+		}
+		if dir == filepath.Dir(dir) {
+			return false
+		}
+	}
+}
+
+// Check whether file changes reach the index, otherwise it may miss files created or deleted since it was built
+func indexWatched() bool {
+	return fileWatchersRegistered.Load() || nativeWatcherCoversIndex()
+}
+
+// Glob "" or "*" below absolutePath using the index, like filepath.Glob
+func indexedPathSuggestions(absolutePath string, joinPath string) ([]string, bool) {
+	if !indexWatched() {
+		return nil, false
+	}
+	indexLock.RLock()
+	defer indexLock.RUnlock()
+	if (joinPath != "" && joinPath != "*") || !indexCoversLocked(absolutePath) {
+		return nil, false
+	}
+
+	if joinPath == "" {
+		if _, ok := indexedChildren[filepath.Dir(absolutePath)][filepath.Base(absolutePath)]; ok || indexedFolders[absolutePath] {
+			return []string{absolutePath}, true
+		}
+		return []string{}, true
+	}
+	suggestedAbsolutePaths := []string{}
+	for name := range indexedChildren[absolutePath] {
+		suggestedAbsolutePaths = append(suggestedAbsolutePaths, filepath.Join(absolutePath, name))
+	}
+	slices.Sort(suggestedAbsolutePaths)
+	return suggestedAbsolutePaths, true
+}
+
+// Check whether path is a directory, from the index when it covers the path and is kept current
+func isDirPath(path string) bool {
+	indexLock.RLock()
+	if indexCoversLocked(filepath.Dir(path)) && indexWatched() {
+		isDir, ok := indexedChildren[filepath.Dir(path)][filepath.Base(path)]
+		indexLock.RUnlock()
+		return ok && isDir
+	}
+	indexLock.RUnlock()
+
+	fileInfo, err := os.Stat(path)
+	return err == nil && fileInfo.IsDir()
+}
+
+// URIs of indexed files, available once every workspace folder is indexed
+func indexedFileUris() ([]string, bool) {
+	workspaceFoldersLock.RLock()
+	folders := slices.Clone(workspaceFolders)
+	workspaceFoldersLock.RUnlock()
+
+	indexLock.RLock()
+	defer indexLock.RUnlock()
+	for _, folder := range folders {
+		if !indexedFolders[folder] {
+			return nil, false
+		}
+	}
+	uris := make([]string, 0, len(indexedReferences))
+	for uri := range indexedReferences {
+		uris = append(uris, uri)
+	}
+	slices.Sort(uris)
+	return uris, true
+}
+
+// Indexed references of a file on disk
+func indexedFileReferences(fileUri string) ([]pathReference, bool) {
+	indexLock.RLock()
+	defer indexLock.RUnlock()
+	references, ok := indexedReferences[fileUri]
+	return references, ok
+}
+
+// URIs of indexed files referring to a path satisfying accept
+func indexedReferrerUris(accept func(absolutePath string) bool) []string {
+	indexLock.RLock()
+	defer indexLock.RUnlock()
+	uris := []string{}
+	for absolutePath, referrers := range indexedReferrers {
+		if !accept(absolutePath) {
+			continue
+		}
+		for uri := range referrers {
+			if !slices.Contains(uris, uri) {
+				uris = append(uris, uri)
+			}
+		}
+	}
+	slices.Sort(uris)
+	return uris
+}
+
+// Check ignore rules of every directory from path up to folder, plus ignored and hidden directories
+func isIgnoredPath(path string, isDir bool, folder string, rulesOf func(dir string) []ignoreRule) bool {
+	name := filepath.Base(path)
+	if isDir && (slices.Contains(ignoredDirNames, name) || strings.HasPrefix(name, ".")) {
+		return true
+	}
+	for dir := filepath.Dir(path); isWithinDir(dir, folder); dir = filepath.Dir(dir) {
+		rel, _ := filepath.Rel(dir, path)
+		for _, rule := range rulesOf(dir) {
+			if rule.DirOnly && !isDir {
+				continue
+			}
+			subject := name
+			if rule.Anchored {
+				subject = rel
+			}
+			if matched, _ := filepath.Match(rule.Pattern, subject); matched {
+				return true
+			}
+		}
+		if dir == folder {
+			break
+		}
+	}
+	return false
+}
+
+// Parse the ".gitignore" of a directory. Negated patterns are not supported.
+func readIgnoreRules(dir string) []ignoreRule {
+	file, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	rules := []ignoreRule{}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		pattern := strings.TrimSpace(scanner.Text())
+		if pattern == "" || strings.HasPrefix(pattern, "#") || strings.HasPrefix(pattern, "!") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasSuffix(pattern, "/") {
+			rule.DirOnly = true
+			pattern = strings.TrimSuffix(pattern, "/")
+		}
+		pattern = strings.TrimPrefix(pattern, "**/")
+		rule.Anchored = strings.Contains(pattern, "/")
+		rule.Pattern = strings.TrimPrefix(pattern, "/")
+		rules = append(rules, rule)
+	}
+	return rules
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Files larger than this are not scanned for path references
+const maxScannedFileSize = 1 << 20
+
+// Directories never scanned for path references
+var ignoredDirNames = []string{"node_modules", ".git", ".hg", ".svn"}
+
+// A path string in a workspace file and the absolute path it resolves to
+type pathReference struct {
+	URI          string
+	Line         int
+	Match        pathMatch
+	AbsolutePath string // Empty when the path does not resolve
+}
+
+// Text of a workspace file, preferring the open document over disk content
+func workspaceFileText(fileUri string) (string, bool) {
+	if currentFile, ok := currentFiles.Get(fileUri); ok {
+		return currentFile.Text(), true
+	}
+	return readFileText(uriPath(fileUri))
+}
+
+// Text of a file on disk, unless it is too large or binary
+func readFileText(path string) (string, bool) {
+	fileInfo, err := os.Stat(path)
+	if err != nil || !fileInfo.Mode().IsRegular() || fileInfo.Size() > maxScannedFileSize {
+		return "", false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil || isBinary(data) {
+		return "", false
+	}
+	return string(data), true
+}
+
+// Binary files usually contain NUL bytes
+func isBinary(data []byte) bool {
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// URIs of text files in workspace folders plus open documents outside of them
+func workspaceFileUris() []string {
+	uris, ok := indexedFileUris()
+	if !ok {
+		uris = walkWorkspaceFileUris()
+	}
+	for _, currentFile := range currentFiles.All() {
+		if !slices.Contains(uris, currentFile.Path) {
+			uris = append(uris, currentFile.Path)
+		}
+	}
+	return uris
+}
+
+// Walk workspace folders while the index is being built
+func walkWorkspaceFileUris() []string {
+	workspaceFoldersLock.RLock()
+	folders := slices.Clone(workspaceFolders)
+	workspaceFoldersLock.RUnlock()
+
+	uris := []string{}
+	for _, folder := range folders {
+		uris = append(uris, walkFileUris(folder, folder)...)
+	}
+	return uris
+}
+
+// URIs of files in root small enough to scan, skipping paths ignored like the index would,
+// by ".gitignore" files from root's enclosing folder down
+func walkFileUris(root string, folder string) []string {
+	ignoreRules := map[string][]ignoreRule{}
+	rulesOf := func(dir string) []ignoreRule {
+		if rules, ok := ignoreRules[dir]; ok {
+			return rules
+		}
+		ignoreRules[dir] = readIgnoreRules(dir)
+		return ignoreRules[dir]
+	}
+
+	uris := []string{}
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != root && isIgnoredPath(path, entry.IsDir(), folder, rulesOf) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if fileInfo, err := entry.Info(); err != nil || !fileInfo.Mode().IsRegular() || fileInfo.Size() > maxScannedFileSize {
+			return nil
+		}
+		uris = append(uris, "file://"+path)
+		return nil
+	})
+	return uris
+}
+
+// Find path references in text, leaving AbsolutePath empty for paths that do not resolve
+func textPathReferences(fileUri string, text string, languageID string) []pathReference {
+	references := []pathReference{}
+	for i, line := range textLines(text) {
+		for _, match := range linePathMatches(line, languageID) {
+			references = append(references, pathReference{
+				URI:          fileUri,
+				Line:         i,
+				Match:        match,
+				AbsolutePath: resolvePathReference(match.Text, fileUri, languageID),
+			})
+		}
+	}
+	return references
+}
+
+// First absolute path a path string resolves to, or ""
+func resolvePathReference(path string, fileUri string, languageID string) string {
+	absolutePaths := matchPathInLanguage(path, fileUri, "", languageID)
+	if len(absolutePaths) == 0 {
+		return ""
+	}
+	return absolutePaths[0]
+}
+
+// Find path references of a file whose resolved path satisfies accept.
+// Open documents are scanned live, other files come from the index when it has them.
+func filePathReferences(fileUri string, accept func(absolutePath string) bool) []pathReference {
+	references, ok := []pathReference(nil), false
+	if _, open := currentFiles.Get(fileUri); !open {
+		references, ok = indexedFileReferences(fileUri)
+	}
+	if !ok {
+		text, ok := workspaceFileText(fileUri)
+		if !ok {
+			return []pathReference{}
+		}
+		references = textPathReferences(fileUri, text, languageIDOf(fileUri))
+	}
+
+	accepted := []pathReference{}
+	for _, reference := range references {
+		if reference.AbsolutePath != "" && accept(reference.AbsolutePath) {
+			accepted = append(accepted, reference)
+		}
+	}
+	return accepted
+}
+
+// Find path references across the workspace whose resolved path satisfies accept
+func workspacePathReferences(accept func(absolutePath string) bool) []pathReference {
+	references := []pathReference{}
+	for _, uri := range referringFileUris(accept) {
+		references = append(references, filePathReferences(uri, accept)...)
+	}
+	return references
+}
+
+// URIs of files that may refer to a path satisfying accept, narrowed by the reverse index when built
+func referringFileUris(accept func(absolutePath string) bool) []string {
+	if _, ok := indexedFileUris(); !ok {
+		return workspaceFileUris()
+	}
+	uris := indexedReferrerUris(accept)
+	for _, currentFile := range currentFiles.All() {
+		if !slices.Contains(uris, currentFile.Path) {
+			uris = append(uris, currentFile.Path)
+		}
+	}
+	return uris
+}
@@ -1,73 +1,154 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
+	"log"
 	"log/slog"
 	"os"
 	"path-intellisense-lsp/src/glsp"
 	"path-intellisense-lsp/src/handlers"
-	"path-intellisense-lsp/src/server"
+	"path/filepath"
 	"strings"
 
 	protocol "path-intellisense-lsp/src/protocol_3_16"
+	protocol317 "path-intellisense-lsp/src/protocol_3_17"
 )
 
 var (
 	lspName        = "Path intellisense lsp"
 	version string = "0.0.1"
-	handler protocol.Handler
+	handler protocol317.Handler
+
+	fileScheme = "file"
 )
 
+// Subcommands, the server is started when none is given as editors run it with flags only
 func main() {
-	switch strings.ToUpper(os.Getenv("LOG_LEVEL")) {
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+	switch command {
+	case "serve":
+		os.Exit(serve(args))
+	case "check":
+		os.Exit(check(args))
+	case "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, `Usage: %[1]s <command> [flags]
+
+Commands:
+  serve               Serve the language server, the default
+  check [paths...]    Report paths in files that don't resolve
+
+Run %[1]s <command> -h for the flags of a command
+`, filepath.Base(os.Args[0]))
+}
+
+// Log to stderr, or to a file so clients reading stderr aren't flooded
+func setupLogging(level string, logFile string) error {
+	switch strings.ToUpper(level) {
 	case "DEBUG":
 		slog.SetLogLoggerLevel(slog.LevelDebug)
+	case "", "INFO":
+		slog.SetLogLoggerLevel(slog.LevelInfo)
 	case "WARN":
 		slog.SetLogLoggerLevel(slog.LevelWarn)
 	case "ERROR":
 		slog.SetLogLoggerLevel(slog.LevelError)
+	default:
+		return fmt.Errorf("unknown log level %q, expected DEBUG, INFO, WARN or ERROR", level)
 	}
-
-	handler = protocol.Handler{
-		// Lifecycle
-		Initialize:  initialize,
-		Initialized: handlers.Initialized,
-		SetTrace:    handlers.SetTrace,
-		LogTrace:    handlers.LogTrace,
-		Shutdown:    handlers.Shutdown,
-		Exit:        handlers.Exit,
-		// Handlers for basic
-		CancelRequest: handlers.CancelRequest,
-		// Handlers for file syncing
-		TextDocumentDidOpen:   handlers.TextDocumentDidOpen,
-		TextDocumentDidSave:   handlers.TextDocumentDidSave,
-		TextDocumentDidClose:  handlers.TextDocumentDidClose,
-		TextDocumentDidChange: handlers.TextDocumentDidChange,
-		// Handlers for code completion
-		TextDocumentCompletion: handlers.TextDocumentCompletion,
-		// Handlers for navigation
-		TextDocumentDocumentLink: handlers.TextDocumentDocumentLink,
+	if logFile != "" {
+		file, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		log.SetOutput(file)
 	}
+	return nil
+}
 
-	server := server.NewServer(&handler)
-
-	err := server.RunStdio()
-	if err == nil {
-		slog.Error("Couldn't run server")
+func newHandler() protocol317.Handler {
+	return protocol317.Handler{
+		Handler: protocol.Handler{
+			// Lifecycle
+			Initialized: handlers.Initialized,
+			SetTrace:    handlers.SetTrace,
+			LogTrace:    handlers.LogTrace,
+			Shutdown:    handlers.Shutdown,
+			Exit:        handlers.Exit,
+			// Handlers for workspace
+			WorkspaceDidChangeWorkspaceFolders: handlers.WorkspaceDidChangeWorkspaceFolders,
+			WorkspaceDidChangeWatchedFiles:     handlers.WorkspaceDidChangeWatchedFiles,
+			WorkspaceDidChangeConfiguration:    handlers.WorkspaceDidChangeConfiguration,
+			WorkspaceWillRenameFiles:           handlers.WorkspaceWillRenameFiles,
+			WorkspaceDidRenameFiles:            handlers.WorkspaceDidRenameFiles,
+			// Handlers for file syncing
+			TextDocumentDidOpen:   handlers.TextDocumentDidOpen,
+			TextDocumentDidSave:   handlers.TextDocumentDidSave,
+			TextDocumentDidClose:  handlers.TextDocumentDidClose,
+			TextDocumentDidChange: handlers.TextDocumentDidChange,
+			// Handlers for code completion
+			TextDocumentCompletion: handlers.TextDocumentCompletion,
+			// Handlers for code actions
+			TextDocumentCodeAction: handlers.TextDocumentCodeAction,
+			// Handlers for navigation
+			TextDocumentDocumentLink: handlers.TextDocumentDocumentLink,
+			TextDocumentHover:        handlers.TextDocumentHover,
+			TextDocumentDefinition:   handlers.TextDocumentDefinition,
+			TextDocumentDeclaration:  handlers.TextDocumentDeclaration,
+			TextDocumentReferences:   handlers.TextDocumentReferences,
+			// Handlers for refactoring
+			TextDocumentPrepareRename: handlers.TextDocumentPrepareRename,
+			TextDocumentRename:        handlers.TextDocumentRename,
+		},
+		// Lifecycle
+		Initialize: initialize,
+		// Handlers for diagnostics, pulled by clients supporting it and published to others
+		TextDocumentDiagnostic: handlers.TextDocumentDiagnostic,
+		WorkspaceDiagnostic:    handlers.WorkspaceDiagnostic,
 	}
 }
 
-func initialize(ctx *glsp.Context, params *protocol.InitializeParams) (any, error) {
+func initialize(ctx *glsp.Context, params317 *protocol317.InitializeParams) (any, error) {
 	slog.Debug("Initializing server...")
+	// 3.17 capabilities shadow the 3.16 ones they extend, so read those separately
+	var params protocol.InitializeParams
+	if err := json.Unmarshal(ctx.Params, &params); err != nil {
+		return nil, err
+	}
+	handlers.SetClientCapabilities(params.Capabilities, params317.Capabilities)
+	positionEncoding := handlers.NegotiatePositionEncoding(params317.Capabilities)
+	handlers.SetWorkspaceFolders(&params)
+	handlers.ApplyInitializationOptions(&params)
 
 	options := protocol.ServerCapabilitiesOptions{
 		CompletionOptions: &protocol.CompletionOptions{
-			TriggerCharacters: []string{
-				"/",
+			TriggerCharacters: handlers.TriggerCharacters(),
+		},
+		CodeActionOptions: &protocol.CodeActionOptions{
+			CodeActionKinds: []protocol.CodeActionKind{
+				protocol.CodeActionKindQuickFix,
 			},
 		},
+		FileOperationFilters: []protocol.FileOperationFilter{{
+			Scheme:  &fileScheme,
+			Pattern: protocol.FileOperationPattern{Glob: "**/*"},
+		}},
 	}
 	capabilities := handler.CreateServerCapabilities(&options)
-	initializeResult := protocol.InitializeResult{
+	capabilities.PositionEncoding = &positionEncoding
+	initializeResult := protocol317.InitializeResult{
 		Capabilities: capabilities,
 		ServerInfo: &protocol.InitializeResultServerInfo{
 			Name:    lspName,
@@ -94,6 +94,37 @@ func (s BoolOrString) String() string {
 	}
 }
 
+// https://microsoft.github.io/language-server-protocol/specifications/specification-3-16#responseMessage
+
+type ErrorCode = int64
+
+const (
+	/**
+	 * Error code indicating that a server received a notification or
+	 * request before the server has received the `initialize` request.
+	 */
+	ErrorCodeServerNotInitialized = ErrorCode(-32002)
+	ErrorCodeUnknownErrorCode     = ErrorCode(-32001)
+
+	/**
+	 * The server detected that the content of a document got
+	 * modified outside normal conditions. A server should
+	 * NOT send this error code if it detects a content change
+	 * in it unprocessed messages. The result even computed
+	 * on an older state might still be useful for the client.
+	 *
+	 * If a client decides that a result is not of any use anymore
+	 * the client should cancel the request.
+	 */
+	ErrorCodeContentModified = ErrorCode(-32801)
+
+	/**
+	 * The client has canceled a request and a server as detected
+	 * the cancel.
+	 */
+	ErrorCodeRequestCancelled = ErrorCode(-32800)
+)
+
 // https://microsoft.github.io/language-server-protocol/specifications/specification-3-16#cancelRequest
 
 const MethodCancelRequest = Method("$/cancelRequest")
@@ -143,7 +143,6 @@ func (s *Handler) Handle(ctx *glsp.Context) (r any, validMethod bool, validParam
 		}
 
 	case MethodShutdown:
-		s.SetInitialized(false)
 		if s.Shutdown != nil {
 			validMethod = true
 			validParams = true
@@ -741,7 +740,9 @@ func (s *Handler) SetInitialized(initialized bool) {
 }
 
 type ServerCapabilitiesOptions struct {
-	CompletionOptions *CompletionOptions
+	CompletionOptions    *CompletionOptions
+	CodeActionOptions    *CodeActionOptions
+	FileOperationFilters []FileOperationFilter
 }
 
 func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) ServerCapabilities {
@@ -829,7 +830,11 @@ func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) Serve
 	}
 
 	if s.TextDocumentCodeAction != nil {
-		capabilities.CodeActionProvider = true
+		if opt.CodeActionOptions != nil {
+			capabilities.CodeActionProvider = opt.CodeActionOptions
+		} else {
+			capabilities.CodeActionProvider = true
+		}
 	}
 
 	if s.TextDocumentCodeLens != nil {
@@ -857,7 +862,11 @@ func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) Serve
 	}
 
 	if s.TextDocumentRename != nil {
-		capabilities.RenameProvider = true
+		if s.TextDocumentPrepareRename != nil {
+			capabilities.RenameProvider = &RenameOptions{PrepareProvider: &True}
+		} else {
+			capabilities.RenameProvider = true
+		}
 	}
 
 	if s.TextDocumentFoldingRange != nil {
@@ -909,6 +918,16 @@ func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) Serve
 		capabilities.WorkspaceSymbolProvider = true
 	}
 
+	if s.WorkspaceDidChangeWorkspaceFolders != nil {
+		if capabilities.Workspace == nil {
+			capabilities.Workspace = &ServerCapabilitiesWorkspace{}
+		}
+		capabilities.Workspace.WorkspaceFolders = &WorkspaceFoldersServerCapabilities{
+			Supported:           &True,
+			ChangeNotifications: &BoolOrString{Value: true},
+		}
+	}
+
 	if s.WorkspaceDidCreateFiles != nil {
 		if capabilities.Workspace == nil {
 			capabilities.Workspace = &ServerCapabilitiesWorkspace{}
@@ -917,7 +936,7 @@ func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) Serve
 			capabilities.Workspace.FileOperations = &ServerCapabilitiesWorkspaceFileOperations{}
 		}
 		capabilities.Workspace.FileOperations.DidCreate = &FileOperationRegistrationOptions{
-			Filters: []FileOperationFilter{},
+			Filters: fileOperationFilters(opt),
 		}
 	}
 
@@ -929,12 +948,14 @@ func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) Serve
 			capabilities.Workspace.FileOperations = &ServerCapabilitiesWorkspaceFileOperations{}
 		}
 		capabilities.Workspace.FileOperations.WillCreate = &FileOperationRegistrationOptions{
-			Filters: []FileOperationFilter{},
+			Filters: fileOperationFilters(opt),
 		}
 	}
 
 	if s.WorkspaceDidRenameFiles != nil {
-		capabilities.RenameProvider = true
+		if capabilities.RenameProvider == nil {
+			capabilities.RenameProvider = true
+		}
 		if capabilities.Workspace == nil {
 			capabilities.Workspace = &ServerCapabilitiesWorkspace{}
 		}
@@ -942,12 +963,14 @@ func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) Serve
 			capabilities.Workspace.FileOperations = &ServerCapabilitiesWorkspaceFileOperations{}
 		}
 		capabilities.Workspace.FileOperations.DidRename = &FileOperationRegistrationOptions{
-			Filters: []FileOperationFilter{},
+			Filters: fileOperationFilters(opt),
 		}
 	}
 
 	if s.WorkspaceWillRenameFiles != nil {
-		capabilities.RenameProvider = true
+		if capabilities.RenameProvider == nil {
+			capabilities.RenameProvider = true
+		}
 		if capabilities.Workspace == nil {
 			capabilities.Workspace = &ServerCapabilitiesWorkspace{}
 		}
@@ -955,7 +978,7 @@ func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) Serve
 			capabilities.Workspace.FileOperations = &ServerCapabilitiesWorkspaceFileOperations{}
 		}
 		capabilities.Workspace.FileOperations.WillRename = &FileOperationRegistrationOptions{
-			Filters: []FileOperationFilter{},
+			Filters: fileOperationFilters(opt),
 		}
 	}
 
@@ -967,7 +990,7 @@ func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) Serve
 			capabilities.Workspace.FileOperations = &ServerCapabilitiesWorkspaceFileOperations{}
 		}
 		capabilities.Workspace.FileOperations.DidDelete = &FileOperationRegistrationOptions{
-			Filters: []FileOperationFilter{},
+			Filters: fileOperationFilters(opt),
 		}
 	}
 
@@ -979,9 +1002,17 @@ func (s *Handler) CreateServerCapabilities(opt *ServerCapabilitiesOptions) Serve
 			capabilities.Workspace.FileOperations = &ServerCapabilitiesWorkspaceFileOperations{}
 		}
 		capabilities.Workspace.FileOperations.WillDelete = &FileOperationRegistrationOptions{
-			Filters: []FileOperationFilter{},
+			Filters: fileOperationFilters(opt),
 		}
 	}
 
 	return capabilities
 }
+
+// Filters for file operation registrations, empty unless set in options
+func fileOperationFilters(opt *ServerCapabilitiesOptions) []FileOperationFilter {
+	if opt.FileOperationFilters != nil {
+		return opt.FileOperationFilters
+	}
+	return []FileOperationFilter{}
+}
@@ -1232,7 +1232,7 @@ type ReferenceParams struct {
 	WorkDoneProgressParams
 	PartialResultParams
 
-	Context ReferenceContext `json:"ctx"`
+	Context ReferenceContext `json:"context"`
 }
 
 type ReferenceContext struct {
@@ -1681,7 +1681,7 @@ type CodeActionParams struct {
 	/**
 	 * Context carrying additional information.
 	 */
-	Context CodeActionContext `json:"ctx"`
+	Context CodeActionContext `json:"context"`
 }
 
 /**
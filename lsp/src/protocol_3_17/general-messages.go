@@ -28,8 +28,92 @@ type ClientCapabilities struct {
 	protocol316.ClientCapabilities
 
 	TextDocument *TextDocumentClientCapabilities `json:"textDocument,omitempty"`
+
+	/**
+	 * Workspace specific client capabilities.
+	 *
+	 * Only holds capabilities added in 3.17, it shadows the 3.16 workspace capabilities.
+	 */
+	Workspace *WorkspaceClientCapabilities `json:"workspace,omitempty"`
+
+	/**
+	 * General client capabilities.
+	 *
+	 * @since 3.16.0
+	 */
+	General *GeneralClientCapabilities `json:"general,omitempty"`
 }
 
+type GeneralClientCapabilities struct {
+	/**
+	 * Client capabilities specific to regular expressions.
+	 *
+	 * @since 3.16.0
+	 */
+	RegularExpressions *protocol316.RegularExpressionsClientCapabilities `json:"regularExpressions,omitempty"`
+
+	/**
+	 * Client capabilities specific to the client's markdown parser.
+	 *
+	 * @since 3.16.0
+	 */
+	Markdown *protocol316.MarkdownClientCapabilities `json:"markdown,omitempty"`
+
+	/**
+	 * The position encodings supported by the client. Client and server
+	 * have to agree on the same position encoding to ensure that offsets
+	 * (e.g. character position in a line) are interpreted the same on both
+	 * side.
+	 *
+	 * To keep the protocol backwards compatible the following applies: if
+	 * the value 'utf-16' is missing from the array of position encodings
+	 * servers can assume that the client supports UTF-16. UTF-16 is
+	 * therefore a mandatory encoding.
+	 *
+	 * If omitted it defaults to ['utf-16'].
+	 *
+	 * Implementation considerations: since the conversion from one encoding
+	 * into another requires the content of the file / line the conversion
+	 * is best done where the file is read which is usually on the server
+	 * side.
+	 *
+	 * @since 3.17.0
+	 */
+	PositionEncodings []PositionEncodingKind `json:"positionEncodings,omitempty"`
+}
+
+/**
+ * A type indicating how positions are encoded,
+ * specifically what column offsets mean.
+ *
+ * @since 3.17.0
+ */
+type PositionEncodingKind string
+
+const (
+	/**
+	 * Character offsets count UTF-8 code units (e.g bytes).
+	 */
+	PositionEncodingKindUTF8 = PositionEncodingKind("utf-8")
+
+	/**
+	 * Character offsets count UTF-16 code units.
+	 *
+	 * This is the default and must always be supported
+	 * by servers
+	 */
+	PositionEncodingKindUTF16 = PositionEncodingKind("utf-16")
+
+	/**
+	 * Character offsets count UTF-32 code units.
+	 *
+	 * Implementation note: these are the same as Unicode code points,
+	 * so this `PositionEncodingKind` may also be used for an
+	 * encoding-agnostic representation of character offsets.
+	 */
+	PositionEncodingKindUTF32 = PositionEncodingKind("utf-32")
+)
+
 /**
  * Text document specific client capabilities.
  */
@@ -44,9 +128,34 @@ type TextDocumentClientCapabilities struct {
 	Diagnostic *DiagnosticClientCapabilities `json:"diagnostic,omitempty"`
 }
 
+/**
+ * Workspace specific client capabilities added in 3.17.
+ */
+type WorkspaceClientCapabilities struct {
+	/**
+	 * Client workspace capabilities specific to diagnostics.
+	 *
+	 * @since 3.17.0.
+	 */
+	Diagnostics *DiagnosticWorkspaceClientCapabilities `json:"diagnostics,omitempty"`
+}
+
 type ServerCapabilities struct {
 	protocol316.ServerCapabilities
 
+	/**
+	 * The position encoding the server picked from the encodings offered
+	 * by the client via the client capability `general.positionEncodings`.
+	 *
+	 * If the client didn't provide any position encodings the only valid
+	 * value that a server can return is 'utf-16'.
+	 *
+	 * If omitted it defaults to 'utf-16'.
+	 *
+	 * @since 3.17.0
+	 */
+	PositionEncoding *PositionEncodingKind `json:"positionEncoding,omitempty"`
+
 	/**
 	 * The server has support for pull model diagnostics.
 	 *
@@ -87,6 +196,7 @@ func (s *ServerCapabilities) UnmarshalJSON(data []byte) error {
 		Workspace                        *protocol316.ServerCapabilitiesWorkspace     `json:"workspace,omitempty"`
 		Experimental                     *any                                         `json:"experimental,omitempty"`
 		DiagnosticProvider               json.RawMessage                              `json:"diagnosticProvider,omitempty"` // nil | DiagnosticOptions | DiagnosticRegistrationOptions
+		PositionEncoding                 *PositionEncodingKind                        `json:"positionEncoding,omitempty"`
 	}
 
 	if err := json.Unmarshal(data, &value); err == nil {
@@ -97,6 +207,7 @@ func (s *ServerCapabilities) UnmarshalJSON(data []byte) error {
 		s.DocumentOnTypeFormattingProvider = value.DocumentOnTypeFormattingProvider
 		s.ExecuteCommandProvider = value.ExecuteCommandProvider
 		s.Workspace = value.Workspace
+		s.PositionEncoding = value.PositionEncoding
 
 		if value.TextDocumentSync != nil {
 			var value_ protocol316.TextDocumentSyncOptions
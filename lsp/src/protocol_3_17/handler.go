@@ -15,6 +15,7 @@ type Handler struct {
 
 	Initialize             InitializeFunc
 	TextDocumentDiagnostic TextDocumentDiagnosticFunc
+	WorkspaceDiagnostic    WorkspaceDiagnosticFunc
 
 	initialized bool
 	lock        sync.Mutex
@@ -71,7 +72,6 @@ func (s *Handler) Handle(ctx *glsp.Context) (r any, validMethod bool, validParam
 		}
 
 	case protocol316.MethodShutdown:
-		s.SetInitialized(false)
 		if s.Shutdown != nil {
 			validMethod = true
 			validParams = true
@@ -650,6 +650,16 @@ func (s *Handler) Handle(ctx *glsp.Context) (r any, validMethod bool, validParam
 			}
 		}
 
+	case MethodWorkspaceDiagnostic:
+		if s.WorkspaceDiagnostic != nil {
+			validMethod = true
+			var params WorkspaceDiagnosticParams
+			if err = json.Unmarshal(ctx.Params, &params); err == nil {
+				validParams = true
+				r, err = s.WorkspaceDiagnostic(ctx, &params)
+			}
+		}
+
 	default:
 		if s.CustomRequest != nil {
 			if handler, ok := s.CustomRequest[ctx.Method]; ok && (handler.Func != nil) {
@@ -678,245 +688,16 @@ func (s *Handler) SetInitialized(initialized bool) {
 	s.initialized = initialized
 }
 
-func (s *Handler) CreateServerCapabilities() ServerCapabilities {
-	var capabilities ServerCapabilities
-
-	if (s.TextDocumentDidOpen != nil) || (s.TextDocumentDidClose != nil) {
-		if _, ok := capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions); !ok {
-			capabilities.TextDocumentSync = &protocol316.TextDocumentSyncOptions{}
-		}
-		capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions).OpenClose = &protocol316.True
-	}
-
-	if s.TextDocumentDidChange != nil {
-		if _, ok := capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions); !ok {
-			capabilities.TextDocumentSync = &protocol316.TextDocumentSyncOptions{}
-		}
-		// This can be overriden to TextDocumentSyncKindFull
-		value := protocol316.TextDocumentSyncKindIncremental
-		capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions).Change = &value
-	}
-
-	if s.TextDocumentWillSave != nil {
-		if _, ok := capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions); !ok {
-			capabilities.TextDocumentSync = &protocol316.TextDocumentSyncOptions{}
-		}
-		capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions).WillSave = &protocol316.True
-	}
-
-	if s.TextDocumentWillSaveWaitUntil != nil {
-		if _, ok := capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions); !ok {
-			capabilities.TextDocumentSync = &protocol316.TextDocumentSyncOptions{}
-		}
-		capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions).WillSaveWaitUntil = &protocol316.True
-	}
-
-	if s.TextDocumentDidSave != nil {
-		if _, ok := capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions); !ok {
-			capabilities.TextDocumentSync = &protocol316.TextDocumentSyncOptions{}
-		}
-		capabilities.TextDocumentSync.(*protocol316.TextDocumentSyncOptions).Save = &protocol316.True
-	}
-
-	if s.TextDocumentCompletion != nil {
-		capabilities.CompletionProvider = &protocol316.CompletionOptions{}
-	}
-
-	if s.TextDocumentHover != nil {
-		capabilities.HoverProvider = true
-	}
-
-	if s.TextDocumentSignatureHelp != nil {
-		capabilities.SignatureHelpProvider = &protocol316.SignatureHelpOptions{}
-	}
-
-	if s.TextDocumentDeclaration != nil {
-		capabilities.DeclarationProvider = true
-	}
-
-	if s.TextDocumentDefinition != nil {
-		capabilities.DefinitionProvider = true
-	}
-
-	if s.TextDocumentTypeDefinition != nil {
-		capabilities.TypeDefinitionProvider = true
-	}
-
-	if s.TextDocumentImplementation != nil {
-		capabilities.ImplementationProvider = true
-	}
-
-	if s.TextDocumentReferences != nil {
-		capabilities.ReferencesProvider = true
-	}
-
-	if s.TextDocumentDocumentHighlight != nil {
-		capabilities.DocumentHighlightProvider = true
-	}
-
-	if s.TextDocumentDocumentSymbol != nil {
-		capabilities.DocumentSymbolProvider = true
-	}
-
-	if s.TextDocumentCodeAction != nil {
-		capabilities.CodeActionProvider = true
-	}
-
-	if s.TextDocumentCodeLens != nil {
-		capabilities.CodeLensProvider = &protocol316.CodeLensOptions{}
-	}
-
-	if s.TextDocumentDocumentLink != nil {
-		capabilities.DocumentLinkProvider = &protocol316.DocumentLinkOptions{}
-	}
-
-	if s.TextDocumentColor != nil {
-		capabilities.ColorProvider = true
-	}
-
-	if s.TextDocumentFormatting != nil {
-		capabilities.DocumentFormattingProvider = true
-	}
-
-	if s.TextDocumentRangeFormatting != nil {
-		capabilities.DocumentRangeFormattingProvider = true
-	}
-
-	if s.TextDocumentOnTypeFormatting != nil {
-		capabilities.DocumentOnTypeFormattingProvider = &protocol316.DocumentOnTypeFormattingOptions{}
-	}
-
-	if s.TextDocumentRename != nil {
-		capabilities.RenameProvider = true
-	}
-
-	if s.TextDocumentFoldingRange != nil {
-		capabilities.FoldingRangeProvider = true
-	}
-
-	if s.WorkspaceExecuteCommand != nil {
-		capabilities.ExecuteCommandProvider = &protocol316.ExecuteCommandOptions{}
-	}
-
-	if s.TextDocumentSelectionRange != nil {
-		capabilities.SelectionRangeProvider = true
-	}
-
-	if s.TextDocumentLinkedEditingRange != nil {
-		capabilities.LinkedEditingRangeProvider = true
-	}
-
-	if s.TextDocumentPrepareCallHierarchy != nil {
-		capabilities.CallHierarchyProvider = true
-	}
-
-	if s.TextDocumentSemanticTokensFull != nil {
-		if _, ok := capabilities.SemanticTokensProvider.(*protocol316.SemanticTokensOptions); !ok {
-			capabilities.SemanticTokensProvider = &protocol316.SemanticTokensOptions{}
-		}
-		if s.TextDocumentSemanticTokensFullDelta != nil {
-			capabilities.SemanticTokensProvider.(*protocol316.SemanticTokensOptions).Full = &protocol316.SemanticDelta{}
-			capabilities.SemanticTokensProvider.(*protocol316.SemanticTokensOptions).Full.(*protocol316.SemanticDelta).Delta = &protocol316.True
-		} else {
-			capabilities.SemanticTokensProvider.(*protocol316.SemanticTokensOptions).Full = true
-		}
-	}
-
-	if s.TextDocumentSemanticTokensRange != nil {
-		if _, ok := capabilities.SemanticTokensProvider.(*protocol316.SemanticTokensOptions); !ok {
-			capabilities.SemanticTokensProvider = &protocol316.SemanticTokensOptions{}
-		}
-		capabilities.SemanticTokensProvider.(*protocol316.SemanticTokensOptions).Range = true
-	}
-
-	// TODO: s.TextDocumentSemanticTokensRefresh?
-
-	if s.TextDocumentMoniker != nil {
-		capabilities.MonikerProvider = true
-	}
-
-	if s.WorkspaceSymbol != nil {
-		capabilities.WorkspaceSymbolProvider = true
-	}
-
-	if s.WorkspaceDidCreateFiles != nil {
-		if capabilities.Workspace == nil {
-			capabilities.Workspace = &protocol316.ServerCapabilitiesWorkspace{}
-		}
-		if capabilities.Workspace.FileOperations == nil {
-			capabilities.Workspace.FileOperations = &protocol316.ServerCapabilitiesWorkspaceFileOperations{}
-		}
-		capabilities.Workspace.FileOperations.DidCreate = &protocol316.FileOperationRegistrationOptions{
-			Filters: []protocol316.FileOperationFilter{},
-		}
-	}
-
-	if s.WorkspaceWillCreateFiles != nil {
-		if capabilities.Workspace == nil {
-			capabilities.Workspace = &protocol316.ServerCapabilitiesWorkspace{}
-		}
-		if capabilities.Workspace.FileOperations == nil {
-			capabilities.Workspace.FileOperations = &protocol316.ServerCapabilitiesWorkspaceFileOperations{}
-		}
-		capabilities.Workspace.FileOperations.WillCreate = &protocol316.FileOperationRegistrationOptions{
-			Filters: []protocol316.FileOperationFilter{},
-		}
-	}
-
-	if s.WorkspaceDidRenameFiles != nil {
-		capabilities.RenameProvider = true
-		if capabilities.Workspace == nil {
-			capabilities.Workspace = &protocol316.ServerCapabilitiesWorkspace{}
-		}
-		if capabilities.Workspace.FileOperations == nil {
-			capabilities.Workspace.FileOperations = &protocol316.ServerCapabilitiesWorkspaceFileOperations{}
-		}
-		capabilities.Workspace.FileOperations.DidRename = &protocol316.FileOperationRegistrationOptions{
-			Filters: []protocol316.FileOperationFilter{},
-		}
-	}
-
-	if s.WorkspaceWillRenameFiles != nil {
-		capabilities.RenameProvider = true
-		if capabilities.Workspace == nil {
-			capabilities.Workspace = &protocol316.ServerCapabilitiesWorkspace{}
-		}
-		if capabilities.Workspace.FileOperations == nil {
-			capabilities.Workspace.FileOperations = &protocol316.ServerCapabilitiesWorkspaceFileOperations{}
-		}
-		capabilities.Workspace.FileOperations.WillRename = &protocol316.FileOperationRegistrationOptions{
-			Filters: []protocol316.FileOperationFilter{},
-		}
-	}
-
-	if s.WorkspaceDidDeleteFiles != nil {
-		if capabilities.Workspace == nil {
-			capabilities.Workspace = &protocol316.ServerCapabilitiesWorkspace{}
-		}
-		if capabilities.Workspace.FileOperations == nil {
-			capabilities.Workspace.FileOperations = &protocol316.ServerCapabilitiesWorkspaceFileOperations{}
-		}
-		capabilities.Workspace.FileOperations.DidDelete = &protocol316.FileOperationRegistrationOptions{
-			Filters: []protocol316.FileOperationFilter{},
-		}
-	}
-
-	if s.WorkspaceWillDeleteFiles != nil {
-		if capabilities.Workspace == nil {
-			capabilities.Workspace = &protocol316.ServerCapabilitiesWorkspace{}
-		}
-		if capabilities.Workspace.FileOperations == nil {
-			capabilities.Workspace.FileOperations = &protocol316.ServerCapabilitiesWorkspaceFileOperations{}
-		}
-		capabilities.Workspace.FileOperations.WillDelete = &protocol316.FileOperationRegistrationOptions{
-			Filters: []protocol316.FileOperationFilter{},
-		}
+// Capabilities of the 3.16 handlers, plus the ones only known to 3.17
+func (s *Handler) CreateServerCapabilities(opt *protocol316.ServerCapabilitiesOptions) ServerCapabilities {
+	capabilities := ServerCapabilities{
+		ServerCapabilities: s.Handler.CreateServerCapabilities(opt),
 	}
 
 	if s.TextDocumentDiagnostic != nil {
 		capabilities.DiagnosticProvider = DiagnosticOptions{
 			InterFileDependencies: true,
-			WorkspaceDiagnostics:  false,
+			WorkspaceDiagnostics:  s.WorkspaceDiagnostic != nil,
 		}
 	}
 
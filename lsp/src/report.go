@@ -0,0 +1,384 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"path-intellisense-lsp/src/handlers"
+	"path/filepath"
+	"strings"
+
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+)
+
+// Output formats of the check command
+var reportFormats = map[string]func(io.Writer, *checkReport) error{
+	"text":   writeTextReport,
+	"json":   writeJSONReport,
+	"sarif":  writeSARIFReport,
+	"junit":  writeJUnitReport,
+	"github": writeGitHubReport,
+}
+
+// Rule id of broken paths in SARIF and JUnit reports
+const pathNotFoundRule = "path-not-found"
+
+type checkReport struct {
+	*handlers.CheckResult
+	WorkspaceFolder string // Root of paths in SARIF reports, as code scanning expects paths within the repository
+}
+
+func brokenPathMessage(brokenPath handlers.BrokenPath) string {
+	return fmt.Sprintf("Path not found: %s", brokenPath.Path)
+}
+
+// Where the path was looked for and how to fix it, for formats without fields for them
+func brokenPathDetails(brokenPath handlers.BrokenPath) string {
+	details := []string{}
+	if brokenPath.Base != "" {
+		details = append(details, fmt.Sprintf("resolved against %s base %s", brokenPath.Base, brokenPath.BaseDir))
+	}
+	if brokenPath.Fix != nil {
+		details = append(details, fmt.Sprintf("suggested fix: %s", brokenPath.Fix.Path))
+	}
+	return strings.Join(details, ", ")
+}
+
+// file:line:col lines, like compilers print them
+func writeTextReport(w io.Writer, report *checkReport) error {
+	for _, brokenPath := range report.BrokenPaths {
+		line := fmt.Sprintf("%s:%d:%d: path not found: %s", displayPath(brokenPath.File), brokenPath.Range.Start.Line+1, brokenPath.Range.Start.Character+1, brokenPath.Path)
+		if details := brokenPathDetails(brokenPath); details != "" {
+			line += fmt.Sprintf(" (%s)", details)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type jsonReport struct {
+	Files    int           `json:"files"` // Number of files checked
+	Findings []jsonFinding `json:"findings"`
+}
+
+type jsonFinding struct {
+	File           string              `json:"file"`
+	Range          protocol.Range      `json:"range"` // Zero-based, characters in UTF-16 code units like LSP
+	Path           string              `json:"path"`
+	ResolutionBase *jsonResolutionBase `json:"resolutionBase,omitempty"`
+	ExpectedPath   string              `json:"expectedPath,omitempty"`
+	Fix            *jsonFix            `json:"fix,omitempty"`
+}
+
+type jsonResolutionBase struct {
+	Kind      string `json:"kind"`
+	Directory string `json:"directory"`
+}
+
+type jsonFix struct {
+	Title string `json:"title"`
+	Path  string `json:"path"`
+}
+
+func writeJSONReport(w io.Writer, report *checkReport) error {
+	output := jsonReport{Files: len(report.Files), Findings: []jsonFinding{}}
+	for _, brokenPath := range report.BrokenPaths {
+		finding := jsonFinding{
+			File:         displayPath(brokenPath.File),
+			Range:        brokenPath.Range,
+			Path:         brokenPath.Path,
+			ExpectedPath: brokenPath.ExpectedPath,
+		}
+		if brokenPath.Base != "" {
+			finding.ResolutionBase = &jsonResolutionBase{Kind: brokenPath.Base, Directory: brokenPath.BaseDir}
+		}
+		if brokenPath.Fix != nil {
+			finding.Fix = &jsonFix{Title: brokenPath.Fix.Title, Path: brokenPath.Fix.Path}
+		}
+		output.Findings = append(output.Findings, finding)
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(output)
+}
+
+// See: https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool               sarifTool                        `json:"tool"`
+	ColumnKind         string                           `json:"columnKind"`
+	OriginalURIBaseIDs map[string]sarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []sarifResult                    `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name    string      `json:"name"`
+	Version string      `json:"version"`
+	Rules   []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string                 `json:"id"`
+	ShortDescription     sarifMessage           `json:"shortDescription"`
+	DefaultConfiguration sarifRuleConfiguration `json:"defaultConfiguration"`
+}
+
+type sarifRuleConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      string          `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Fixes      []sarifFix      `json:"fixes,omitempty"`
+	Properties map[string]any  `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI       string `json:"uri"`
+	URIBaseID string `json:"uriBaseId,omitempty"`
+}
+
+// One-based lines and columns
+type sarifRegion struct {
+	StartLine   uint32 `json:"startLine"`
+	StartColumn uint32 `json:"startColumn"`
+	EndLine     uint32 `json:"endLine"`
+	EndColumn   uint32 `json:"endColumn"`
+}
+
+type sarifFix struct {
+	Description     sarifMessage          `json:"description"`
+	ArtifactChanges []sarifArtifactChange `json:"artifactChanges"`
+}
+
+type sarifArtifactChange struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Replacements     []sarifReplacement    `json:"replacements"`
+}
+
+type sarifReplacement struct {
+	DeletedRegion   sarifRegion  `json:"deletedRegion"`
+	InsertedContent sarifMessage `json:"insertedContent"`
+}
+
+// Base id of the workspace folder, which uploads resolve against the repository root
+const sarifSourceRoot = "SRCROOT"
+
+func writeSARIFReport(w io.Writer, report *checkReport) error {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:    lspName,
+			Version: version,
+			Rules: []sarifRule{{
+				ID:                   pathNotFoundRule,
+				ShortDescription:     sarifMessage{Text: "Path not found"},
+				DefaultConfiguration: sarifRuleConfiguration{Level: sarifLevel(report.Severity)},
+			}},
+		}},
+		// Ranges are computed in the default LSP position encoding
+		ColumnKind: "utf16CodeUnits",
+		OriginalURIBaseIDs: map[string]sarifArtifactLocation{
+			sarifSourceRoot: {URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(report.WorkspaceFolder) + "/"}).String()},
+		},
+		Results: []sarifResult{},
+	}
+	for _, brokenPath := range report.BrokenPaths {
+		location := sarifFileLocation(brokenPath.File, report.WorkspaceFolder)
+		region := sarifRegion{
+			StartLine:   brokenPath.Range.Start.Line + 1,
+			StartColumn: brokenPath.Range.Start.Character + 1,
+			EndLine:     brokenPath.Range.End.Line + 1,
+			EndColumn:   brokenPath.Range.End.Character + 1,
+		}
+		result := sarifResult{
+			RuleID:    pathNotFoundRule,
+			Level:     sarifLevel(report.Severity),
+			Message:   sarifMessage{Text: brokenPathMessage(brokenPath)},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: location, Region: region}}},
+			Properties: map[string]any{
+				"path":                    brokenPath.Path,
+				"resolutionBase":          brokenPath.Base,
+				"resolutionBaseDirectory": brokenPath.BaseDir,
+				"expectedPath":            brokenPath.ExpectedPath,
+			},
+		}
+		if brokenPath.Fix != nil {
+			result.Fixes = []sarifFix{{
+				Description: sarifMessage{Text: brokenPath.Fix.Title},
+				ArtifactChanges: []sarifArtifactChange{{
+					ArtifactLocation: location,
+					Replacements:     []sarifReplacement{{DeletedRegion: region, InsertedContent: sarifMessage{Text: brokenPath.Fix.Path}}},
+				}},
+			}}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	})
+}
+
+// SARIF has no levels below "note", so information and hints are notes
+func sarifLevel(severity protocol.DiagnosticSeverity) string {
+	switch severity {
+	case protocol.DiagnosticSeverityWarning:
+		return "warning"
+	case protocol.DiagnosticSeverityInformation, protocol.DiagnosticSeverityHint:
+		return "note"
+	}
+	return "error"
+}
+
+// Location relative to the workspace folder when within it, otherwise an absolute file URI
+func sarifFileLocation(path string, workspaceFolder string) sarifArtifactLocation {
+	if relativePath, err := filepath.Rel(workspaceFolder, path); err == nil && filepath.IsLocal(relativePath) {
+		return sarifArtifactLocation{URI: (&url.URL{Path: filepath.ToSlash(relativePath)}).String(), URIBaseID: sarifSourceRoot}
+	}
+	return sarifArtifactLocation{URI: (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()}
+}
+
+// See: https://github.com/testmoapp/junitxml
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+// A checked file, failing when it has broken paths
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+func writeJUnitReport(w io.Writer, report *checkReport) error {
+	brokenPathsOf := map[string][]handlers.BrokenPath{}
+	for _, brokenPath := range report.BrokenPaths {
+		brokenPathsOf[brokenPath.File] = append(brokenPathsOf[brokenPath.File], brokenPath)
+	}
+
+	suite := junitTestSuite{Name: "check", Tests: len(report.Files), TestCases: []junitTestCase{}}
+	for _, file := range report.Files {
+		testCase := junitTestCase{ClassName: pathNotFoundRule, Name: displayPath(file)}
+		if brokenPaths := brokenPathsOf[file]; len(brokenPaths) > 0 {
+			var text strings.Builder
+			writeTextReport(&text, &checkReport{CheckResult: &handlers.CheckResult{BrokenPaths: brokenPaths}})
+			testCase.Failure = &junitFailure{
+				Message: fmt.Sprintf("%d paths not found", len(brokenPaths)),
+				Type:    pathNotFoundRule,
+				Text:    text.String(),
+			}
+			suite.Failures++
+		}
+		suite.TestCases = append(suite.TestCases, testCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{
+		Name:     lspName,
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Suites:   []junitTestSuite{suite},
+	}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// Workflow commands GitHub Actions turns into annotations on pull requests.
+// See: https://docs.github.com/en/actions/reference/workflow-commands-for-github-actions#setting-an-error-message
+func writeGitHubReport(w io.Writer, report *checkReport) error {
+	for _, brokenPath := range report.BrokenPaths {
+		message := brokenPathMessage(brokenPath)
+		if details := brokenPathDetails(brokenPath); details != "" {
+			message += fmt.Sprintf(" (%s)", details)
+		}
+		_, err := fmt.Fprintf(w, "::%s file=%s,line=%d,col=%d,endLine=%d,endColumn=%d,title=%s::%s\n",
+			gitHubCommand(report.Severity),
+			escapeGitHubProperty(displayPath(brokenPath.File)),
+			brokenPath.Range.Start.Line+1, brokenPath.Range.Start.Character+1,
+			brokenPath.Range.End.Line+1, brokenPath.Range.End.Character+1,
+			escapeGitHubProperty("Path not found"),
+			escapeGitHubData(message),
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Annotation command of a severity, information and hints being notices
+func gitHubCommand(severity protocol.DiagnosticSeverity) string {
+	switch severity {
+	case protocol.DiagnosticSeverityWarning:
+		return "warning"
+	case protocol.DiagnosticSeverityInformation, protocol.DiagnosticSeverityHint:
+		return "notice"
+	}
+	return "error"
+}
+
+func escapeGitHubData(text string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(text)
+}
+
+func escapeGitHubProperty(text string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(text)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path-intellisense-lsp/src/handlers"
+	"path-intellisense-lsp/src/server"
+	"strings"
+	"syscall"
+)
+
+type serveOptions struct {
+	listen      string
+	allowOrigin string
+	watchParent bool
+	logLevel    string
+	logFile     string
+	config      string
+}
+
+// Serve the language server over stdio or a listener, returning the exit status
+func serve(args []string) int {
+	var options serveOptions
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	flags.StringVar(&options.listen, "listen", "", "Serve clients connecting to tcp://host:port, unix:///path.sock or ws://host:port/path instead of stdio, each in its own process")
+	flags.StringVar(&options.allowOrigin, "allow-origin", "", "Comma-separated origins, like https://editor.example, whose web pages may connect to a ws:// listener besides its own, or * for any")
+	flags.BoolVar(&options.watchParent, "watch-parent", true, "Exit once the client process from initialize exits")
+	flags.StringVar(&options.logLevel, "log-level", os.Getenv("LOG_LEVEL"), "DEBUG, INFO, WARN or ERROR, defaults to $LOG_LEVEL")
+	flags.StringVar(&options.logFile, "log-file", "", "Append logs to a file instead of stderr")
+	flags.StringVar(&options.config, "config", "", "JSON settings file, shaped like initializationOptions, that clients' settings override")
+	// Passed by clients launching the server over stdio, which is the default
+	flags.Bool("stdio", false, "Serve a single client over stdin and stdout")
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %v\n", flags.Args())
+		return 2
+	}
+
+	if err := setupLogging(options.logLevel, options.logFile); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if options.config != "" {
+		if err := handlers.LoadSettingsFile(options.config); err != nil {
+			slog.Error(err.Error())
+			return 2
+		}
+	}
+
+	if options.listen != "" {
+		if err := serveListener(options); err != nil {
+			slog.Error(fmt.Sprintf("Server stopped: %s", err))
+			return 1
+		}
+		return 0
+	}
+
+	handler = newHandler()
+	server := server.NewServer(&handler)
+	server.WatchParentProcess = options.watchParent
+
+	// Exit status 1 tells the client the server stopped without shutdown
+	if err := server.RunStdio(); err != nil {
+		slog.Error(fmt.Sprintf("Server stopped: %s", err))
+		return 1
+	}
+	return 0
+}
+
+// Serve clients of a listener until interrupted, each by this executable over stdio with the same options.
+// Clients aren't parent processes of the server, so their process ids aren't watched.
+func serveListener(options serveOptions) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	allowedOrigins := []string{}
+	if options.allowOrigin != "" {
+		for _, origin := range strings.Split(options.allowOrigin, ",") {
+			allowedOrigins = append(allowedOrigins, strings.TrimSpace(origin))
+		}
+	}
+	listener, err := server.Listen(options.listen, allowedOrigins)
+	if err != nil {
+		return err
+	}
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupted
+		slog.Info("Closing listener")
+		listener.Close()
+	}()
+
+	args := []string{"serve", "-watch-parent=false", "-log-level=" + options.logLevel, "-log-file=" + options.logFile}
+	if options.config != "" {
+		args = append(args, "-config="+options.config)
+	}
+	return server.ServeIsolated(listener, func() *exec.Cmd {
+		cmd := exec.Command(executable, args...)
+		cmd.Stderr = os.Stderr
+		return cmd
+	})
+}
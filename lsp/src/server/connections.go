@@ -7,11 +7,15 @@ import (
 	"github.com/sourcegraph/jsonrpc2"
 )
 
-func (s *Server) newStreamConnection(stream io.ReadWriteCloser) *jsonrpc2.Conn {
+// Handlers get a context living as long as the connection, so background work they start stops with it
+func (s *Server) newStreamConnection(stream io.ReadWriteCloser) (*jsonrpc2.Conn, *lifecycle) {
 	handler := s.newHandler()
 
-	ctx, cancel := context.WithTimeout(context.Background(), s.StreamTimeout)
-	defer cancel()
-
-	return jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(stream, jsonrpc2.VSCodeObjectCodec{}), handler, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	connection := jsonrpc2.NewConn(ctx, jsonrpc2.NewBufferedStream(stream, jsonrpc2.VSCodeObjectCodec{}), handler, nil)
+	go func() {
+		<-connection.DisconnectNotify()
+		cancel()
+	}()
+	return connection, handler.lifecycle
 }
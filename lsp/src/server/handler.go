@@ -2,31 +2,80 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 	"path-intellisense-lsp/src/glsp"
+	protocol "path-intellisense-lsp/src/protocol_3_16"
 
 	"github.com/sourcegraph/jsonrpc2"
 )
 
 // See: https://github.com/sourcegraph/go-langserver/blob/master/langserver/handler.go#L206
 
-func (s *Server) newHandler() jsonrpc2.Handler {
-	return jsonrpc2.HandlerWithError(s.handle)
+func (s *Server) newHandler() *connectionHandler {
+	h := &connectionHandler{
+		requests:  newInFlightRequests(),
+		lifecycle: newLifecycle(),
+	}
+	h.handler = jsonrpc2.HandlerWithError(func(ctx context.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request) (any, error) {
+		return s.handle(ctx, connection, request, h)
+	})
+	return h
+}
+
+// Handles notifications in order on the connection's read loop and requests concurrently,
+// so $/cancelRequest is read while the request it cancels is still running.
+// Messages the lifecycle doesn't allow yet or anymore are rejected before reaching handlers.
+type connectionHandler struct {
+	handler   jsonrpc2.Handler
+	requests  *inFlightRequests
+	lifecycle *lifecycle
+}
+
+func (h *connectionHandler) Handle(ctx context.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request) {
+	if request.Notif {
+		if !h.lifecycle.acceptNotification(request.Method) {
+			slog.Debug(fmt.Sprintf("Dropped notification: %s", request.Method))
+			return
+		}
+		h.handler.Handle(ctx, connection, request)
+		return
+	}
+	if err := h.lifecycle.acceptRequest(request.Method); err != nil {
+		if err := connection.ReplyWithError(ctx, request.ID, err); err != nil {
+			slog.Error(err.Error())
+		}
+		return
+	}
+	// Tracked before reading on, a cancellation right after the request must find it
+	requestContext := h.requests.start(ctx, request.ID)
+	go func() {
+		defer h.requests.finish(request.ID)
+		if request.Method == protocol.MethodShutdown {
+			// Requests received before shutdown are answered before handlers release resources
+			h.requests.drain(request.ID)
+		}
+		h.handler.Handle(requestContext, connection, request)
+	}()
 }
 
-func (s *Server) handle(ctx context.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request) (any, error) {
+func (s *Server) handle(ctx context.Context, connection *jsonrpc2.Conn, request *jsonrpc2.Request, h *connectionHandler) (any, error) {
 	glspContext := glsp.Context{
 		Method: request.Method,
 		Notify: func(method string, params any) {
-			if err := connection.Notify(ctx, method, params); err != nil {
+			// Notifications may be sent by background work after the request was handled
+			if err := connection.Notify(context.WithoutCancel(ctx), method, params); err != nil {
 				slog.Error(err.Error())
 			}
 		},
-		Call: func(method string, params any, result any) {
-			if err := connection.Call(ctx, method, params, result); err != nil {
+		Call: func(method string, params any, result any) error {
+			// Client calls are awaited outside the read loop and must outlive the dispatching context
+			err := connection.Call(context.WithoutCancel(ctx), method, params, result)
+			if err != nil {
 				slog.Error(err.Error())
 			}
+			return err
 		},
 		Context: ctx,
 	}
@@ -36,20 +85,42 @@ func (s *Server) handle(ctx context.Context, connection *jsonrpc2.Conn, request
 	}
 
 	switch request.Method {
-	case "exit":
-		// We're giving the attached handler a chance to handle it first, but we'll ignore any result
-		_, _, _, err := s.Handler.Handle(&glspContext)
-		if err == nil {
+	case protocol.MethodCancelRequest:
+		var params struct {
+			ID jsonrpc2.ID `json:"id"`
+		}
+		if err := json.Unmarshal(glspContext.Params, &params); err != nil {
 			return nil, err
 		}
-		err = connection.Close()
-		return nil, err
+		h.requests.cancel(params.ID)
+		return nil, nil
+
+	case protocol.MethodExit:
+		// We're giving the attached handler a chance to handle it first, but we'll ignore any result
+		s.Handler.Handle(&glspContext)
+		h.lifecycle.exit()
+		return nil, connection.Close()
 
 	default:
 		// Note: jsonrpc2 will not even call this function if reqest.Params is invalid JSON,
 		// so we don't need to handle jsonrpc2.CodeParseError here
 		result, validMethod, validParams, err := s.Handler.Handle(&glspContext)
-		if !validMethod {
+		if request.Method == protocol.MethodInitialize {
+			initialized := validMethod && validParams && err == nil
+			h.lifecycle.initialized(initialized)
+			if initialized && s.WatchParentProcess {
+				watchParentProcess(connection.DisconnectNotify(), glspContext.Params, func() {
+					h.lifecycle.abort(ErrParentProcessExited)
+					connection.Close()
+				})
+			}
+		}
+		if ctx.Err() != nil {
+			return nil, &jsonrpc2.Error{
+				Code:    protocol.ErrorCodeRequestCancelled,
+				Message: fmt.Sprintf("request cancelled: %s", request.Method),
+			}
+		} else if !validMethod {
 			return nil, &jsonrpc2.Error{
 				Code:    jsonrpc2.CodeMethodNotFound,
 				Message: fmt.Sprintf("method not supported: %s", request.Method),
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os/exec"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Serve every client of a listener in its own server process talking over stdio,
+// so clients share no documents, settings or caches. Returns once the listener is closed
+// and the processes of connected clients exited.
+func ServeIsolated(listener Listener, command func() *exec.Cmd) error {
+	slog.Info(fmt.Sprintf("Listening on %s", listener.Addr()))
+	var (
+		clients     = map[jsonrpc2.ObjectStream]struct{}{}
+		clientsLock sync.Mutex
+		running     sync.WaitGroup
+	)
+	for {
+		client, err := listener.Accept()
+		if err != nil {
+			// Disconnected clients end their processes
+			clientsLock.Lock()
+			for client := range clients {
+				client.Close()
+			}
+			clientsLock.Unlock()
+			running.Wait()
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		clientsLock.Lock()
+		clients[client] = struct{}{}
+		clientsLock.Unlock()
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			serveInProcess(client, command())
+			clientsLock.Lock()
+			delete(clients, client)
+			clientsLock.Unlock()
+		}()
+	}
+}
+
+// Relay messages between a client and a server process until either side ends
+func serveInProcess(client jsonrpc2.ObjectStream, cmd *exec.Cmd) {
+	defer client.Close()
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		slog.Error(err.Error())
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		slog.Error(fmt.Sprintf("Failed to start server process: %s", err))
+		return
+	}
+	slog.Debug(fmt.Sprintf("Started server process %d", cmd.Process.Pid))
+	process := jsonrpc2.NewBufferedStream(processStdio{stdout, stdin}, jsonrpc2.VSCodeObjectCodec{})
+
+	go func() {
+		relayMessages(client, process)
+		// End of input lets the process exit, as with a client closing stdio
+		process.Close()
+	}()
+	relayMessages(process, client)
+	client.Close()
+
+	if err := cmd.Wait(); err != nil {
+		slog.Debug(fmt.Sprintf("Server process %d stopped: %s", cmd.Process.Pid, err))
+	} else {
+		slog.Debug(fmt.Sprintf("Server process %d exited", cmd.Process.Pid))
+	}
+}
+
+// Copy messages until reading or writing fails, messages are passed on without decoding
+func relayMessages(from jsonrpc2.ObjectStream, to jsonrpc2.ObjectStream) {
+	for {
+		var message json.RawMessage
+		if err := from.ReadObject(&message); err != nil {
+			return
+		}
+		if err := to.WriteObject(message); err != nil {
+			return
+		}
+	}
+}
+
+// Stdio of a server process, closing only stdin so its output is read until it exits
+type processStdio struct {
+	io.Reader
+	io.WriteCloser
+}
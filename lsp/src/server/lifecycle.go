@@ -0,0 +1,126 @@
+package server
+
+import (
+	"errors"
+	"sync"
+
+	protocol "path-intellisense-lsp/src/protocol_3_16"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// See: https://microsoft.github.io/language-server-protocol/specifications/specification-3-16#lifeCycleMessages
+
+var (
+	ErrExitWithoutShutdown = errors.New("exit notification without shutdown request")
+	ErrConnectionClosed    = errors.New("connection closed before exit notification")
+	ErrParentProcessExited = errors.New("parent process exited")
+)
+
+type lifecycleState int
+
+const (
+	lifecycleUninitialized lifecycleState = iota
+	lifecycleInitializing                 // initialize request is being handled
+	lifecycleInitialized
+	lifecycleShutdown // shutdown request received, only exit is accepted
+	lifecycleExited
+)
+
+// Lifecycle of a connection, deciding which messages are accepted
+type lifecycle struct {
+	lock  sync.Mutex
+	state lifecycleState
+	err   error // Why the connection ended, nil after shutdown then exit
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{state: lifecycleUninitialized, err: ErrConnectionClosed}
+}
+
+// Accept a request and move to the state it starts, or return the error to reply with
+func (l *lifecycle) acceptRequest(method string) *jsonrpc2.Error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	switch l.state {
+	case lifecycleUninitialized:
+		if method == protocol.MethodInitialize {
+			l.state = lifecycleInitializing
+			return nil
+		}
+		return &jsonrpc2.Error{Code: protocol.ErrorCodeServerNotInitialized, Message: "server not initialized"}
+
+	case lifecycleInitializing:
+		if method == protocol.MethodInitialize {
+			return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "server is already initializing"}
+		}
+		return &jsonrpc2.Error{Code: protocol.ErrorCodeServerNotInitialized, Message: "server not initialized"}
+
+	case lifecycleInitialized:
+		switch method {
+		case protocol.MethodInitialize:
+			return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "server is already initialized"}
+		case protocol.MethodShutdown:
+			l.state = lifecycleShutdown
+		}
+		return nil
+	}
+	return &jsonrpc2.Error{Code: jsonrpc2.CodeInvalidRequest, Message: "server is shutting down"}
+}
+
+// Check whether a notification may be handled, others are dropped as the specification requires
+func (l *lifecycle) acceptNotification(method string) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	switch method {
+	case protocol.MethodExit:
+		return l.state != lifecycleExited
+	case protocol.MethodCancelRequest:
+		// Requests still answered after shutdown can be cancelled
+		return l.state == lifecycleInitialized || l.state == lifecycleShutdown
+	}
+	return l.state == lifecycleInitialized
+}
+
+// Initialize finished, failed attempts may be retried
+func (l *lifecycle) initialized(ok bool) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.state != lifecycleInitializing {
+		return
+	}
+	if ok {
+		l.state = lifecycleInitialized
+	} else {
+		l.state = lifecycleUninitialized
+	}
+}
+
+// Exit notification received, a clean exit requires a shutdown request before
+func (l *lifecycle) exit() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.state == lifecycleShutdown {
+		l.err = nil
+	} else {
+		l.err = ErrExitWithoutShutdown
+	}
+	l.state = lifecycleExited
+}
+
+// Connection ended for a reason other than the client, unless it exited already
+func (l *lifecycle) abort(err error) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.state != lifecycleExited {
+		l.err = err
+		l.state = lifecycleExited
+	}
+}
+
+// Error the connection ended with, nil for a clean shutdown and exit
+func (l *lifecycle) result() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.err
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	"github.com/sourcegraph/jsonrpc2"
+	jsonrpc2websocket "github.com/sourcegraph/jsonrpc2/websocket"
+)
+
+// Accepts clients as streams of JSON-RPC messages
+type Listener interface {
+	Accept() (jsonrpc2.ObjectStream, error)
+	Close() error
+	Addr() net.Addr
+}
+
+// Listen for clients on tcp://host:port, unix:///path.sock or ws://host:port/path.
+// TCP and Unix socket clients frame messages with headers like stdio, WebSocket clients send one message per frame.
+// WebSocket clients are accepted from the listener's own origin and allowedOrigins, "*" allowing any.
+func Listen(address string, allowedOrigins []string) (Listener, error) {
+	listenURL, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid listen address %q: %w", address, err)
+	}
+	switch listenURL.Scheme {
+	case "tcp":
+		return listenStream("tcp", listenURL.Host)
+	case "unix":
+		// unix://relative.sock parses the path as host
+		return listenStream("unix", listenURL.Host+listenURL.Path)
+	case "ws":
+		return listenWebSocket(listenURL, allowedOrigins)
+	}
+	return nil, fmt.Errorf("unsupported listen address %q, expected tcp://, unix:// or ws://", address)
+}
+
+// Listener for byte streams framed like stdio
+type streamListener struct {
+	net.Listener
+}
+
+func listenStream(network string, address string) (Listener, error) {
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return streamListener{listener}, nil
+}
+
+func (l streamListener) Accept() (jsonrpc2.ObjectStream, error) {
+	connection, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	slog.Debug(fmt.Sprintf("Accepted client %s", connection.RemoteAddr()))
+	return jsonrpc2.NewBufferedStream(connection, jsonrpc2.VSCodeObjectCodec{}), nil
+}
+
+// Listener upgrading HTTP requests to the listened path to WebSocket connections
+type webSocketListener struct {
+	listener    net.Listener
+	server      *http.Server
+	upgrader    websocket.Upgrader
+	connections chan jsonrpc2.ObjectStream
+	closed      chan struct{}
+	closeOnce   sync.Once
+}
+
+func listenWebSocket(listenURL *url.URL, allowedOrigins []string) (Listener, error) {
+	for _, origin := range allowedOrigins {
+		if originURL, err := url.Parse(origin); origin != "*" && (err != nil || originURL.Scheme == "" || originURL.Host == "") {
+			return nil, fmt.Errorf("invalid origin %q, expected scheme://host[:port] or *", origin)
+		}
+	}
+	listener, err := net.Listen("tcp", listenURL.Host)
+	if err != nil {
+		return nil, err
+	}
+	l := &webSocketListener{
+		listener:    listener,
+		upgrader:    websocket.Upgrader{CheckOrigin: originChecker(allowedOrigins)},
+		connections: make(chan jsonrpc2.ObjectStream),
+		closed:      make(chan struct{}),
+	}
+	path := listenURL.Path
+	if path == "" {
+		path = "/"
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, l.upgrade)
+	l.server = &http.Server{Handler: mux}
+
+	go func() {
+		if err := l.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(fmt.Sprintf("WebSocket server stopped: %s", err))
+			l.Close()
+		}
+	}()
+	return l, nil
+}
+
+// Cross-origin requests are rejected unless allowed, so other web pages can't read files through the server
+func originChecker(allowedOrigins []string) func(request *http.Request) bool {
+	return func(request *http.Request) bool {
+		origin := request.Header.Get("Origin")
+		// Clients outside a browser send no origin
+		if origin == "" {
+			return true
+		}
+		for _, allowedOrigin := range allowedOrigins {
+			if allowedOrigin == "*" || strings.EqualFold(strings.TrimSuffix(allowedOrigin, "/"), origin) {
+				return true
+			}
+		}
+		originURL, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(originURL.Host, request.Host)
+	}
+}
+
+func (l *webSocketListener) upgrade(writer http.ResponseWriter, request *http.Request) {
+	connection, err := l.upgrader.Upgrade(writer, request, nil)
+	if err != nil {
+		// Upgrade already replied with an error status
+		slog.Debug(fmt.Sprintf("Rejected WebSocket client %s: %s", request.RemoteAddr, err))
+		return
+	}
+	slog.Debug(fmt.Sprintf("Accepted client %s", request.RemoteAddr))
+	select {
+	case l.connections <- jsonrpc2websocket.NewObjectStream(connection):
+	case <-l.closed:
+		connection.Close()
+	}
+}
+
+func (l *webSocketListener) Accept() (jsonrpc2.ObjectStream, error) {
+	select {
+	case connection := <-l.connections:
+		return connection, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *webSocketListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.closed)
+		err = l.server.Close()
+	})
+	return err
+}
+
+func (l *webSocketListener) Addr() net.Addr {
+	return l.listener.Addr()
+}
@@ -0,0 +1,40 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// How often the client process from initialize is checked
+var ParentProcessPollInterval = 3 * time.Second
+
+// Call exited once the process that started the server is gone, e.g. after the editor crashed
+func watchParentProcess(disconnected <-chan struct{}, initializeParams json.RawMessage, exited func()) {
+	var params struct {
+		ProcessID *int `json:"processId"`
+	}
+	if err := json.Unmarshal(initializeParams, &params); err != nil || params.ProcessID == nil || *params.ProcessID <= 0 {
+		return
+	}
+	pid := *params.ProcessID
+	slog.Debug(fmt.Sprintf("Watching parent process %d", pid))
+
+	go func() {
+		ticker := time.NewTicker(ParentProcessPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-disconnected:
+				return
+			case <-ticker.C:
+				if !processAlive(pid) {
+					slog.Warn(fmt.Sprintf("Parent process %d exited", pid))
+					exited()
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,17 @@
+//go:build !unix
+
+package server
+
+import (
+	"os"
+)
+
+// Finding a process fails on Windows once it exited
+func processAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	process.Release()
+	return true
+}
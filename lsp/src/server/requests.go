@@ -0,0 +1,63 @@
+package server
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sourcegraph/jsonrpc2"
+)
+
+// Requests of a connection still being handled, by ID, so $/cancelRequest can cancel them
+type inFlightRequests struct {
+	lock     sync.Mutex
+	finished *sync.Cond // Signalled whenever a request finishes
+	cancels  map[jsonrpc2.ID]context.CancelFunc
+}
+
+func newInFlightRequests() *inFlightRequests {
+	requests := &inFlightRequests{cancels: map[jsonrpc2.ID]context.CancelFunc{}}
+	requests.finished = sync.NewCond(&requests.lock)
+	return requests
+}
+
+// Track a request, returning the context its handler runs with
+func (r *inFlightRequests) start(ctx context.Context, id jsonrpc2.ID) context.Context {
+	requestContext, cancel := context.WithCancel(ctx)
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.cancels[id] = cancel
+	return requestContext
+}
+
+// Stop tracking a request once its response is sent
+func (r *inFlightRequests) finish(id jsonrpc2.ID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if cancel, ok := r.cancels[id]; ok {
+		cancel()
+		delete(r.cancels, id)
+		r.finished.Broadcast()
+	}
+}
+
+// Wait until every request except the given one finished
+func (r *inFlightRequests) drain(except jsonrpc2.ID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for {
+		_, tracked := r.cancels[except]
+		if len(r.cancels) == 0 || (tracked && len(r.cancels) == 1) {
+			return
+		}
+		r.finished.Wait()
+	}
+}
+
+// Cancel a request, ignoring IDs of requests already answered
+func (r *inFlightRequests) cancel(id jsonrpc2.ID) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if cancel, ok := r.cancels[id]; ok {
+		cancel()
+	}
+}
@@ -8,8 +8,7 @@ import (
 
 func (s *Server) RunStdio() error {
 	slog.Info("Reading from stdin, writing to stdout")
-	s.ServeStream(Stdio{})
-	return nil
+	return s.ServeStream(Stdio{})
 }
 
 type Stdio struct{}
@@ -7,8 +7,12 @@ import (
 
 // See: https://github.com/sourcegraph/go-langserver/blob/master/main.go#L179
 
-func (s *Server) ServeStream(stream io.ReadWriteCloser) {
+// Serve a client until the connection closes.
+// Returns nil if the client sent shutdown then exit, as the exit status should reflect.
+func (s *Server) ServeStream(stream io.ReadWriteCloser) error {
 	slog.Debug("new stream connection")
-	<-s.newStreamConnection(stream).DisconnectNotify()
+	connection, lifecycle := s.newStreamConnection(stream)
+	<-connection.DisconnectNotify()
 	slog.Debug("stream connection closed")
+	return lifecycle.result()
 }
@@ -12,12 +12,16 @@ type Server struct {
 	Handler       glsp.Handler
 	Timeout       time.Duration
 	StreamTimeout time.Duration
+
+	// Exit once the client process from initialize exits, off when the client isn't a local parent process
+	WatchParentProcess bool
 }
 
 func NewServer(handler glsp.Handler) *Server {
 	return &Server{
-		Handler:       handler,
-		Timeout:       DefaultTimeout,
-		StreamTimeout: DefaultTimeout,
+		Handler:            handler,
+		Timeout:            DefaultTimeout,
+		StreamTimeout:      DefaultTimeout,
+		WatchParentProcess: true,
 	}
 }